@@ -0,0 +1,95 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type arrayPacket struct {
+	Hash  [16]byte          `tag:"0"`
+	Tuple [4]int32          `tag:"1"`
+	Items [2]taggedInner    `tag:"2"`
+	Empty [0]int8           `tag:"3"`
+	Keys  map[[2]int32]bool `tag:"4"`
+}
+
+type slicePacket struct {
+	Hash  []byte           `tag:"0"`
+	Tuple []int32          `tag:"1"`
+	Items []taggedInner    `tag:"2"`
+	Empty []int8           `tag:"3"`
+	Keys  map[string]int32 `tag:"4"`
+}
+
+func TestArrayCodec(t *testing.T) {
+	val := arrayPacket{
+		Hash:  [16]byte{0: 1, 15: 0xff},
+		Tuple: [4]int32{1, -2, 300, 1 << 20},
+		Items: [2]taggedInner{{Name: "a", Score: 1}, {Name: "b"}},
+		Keys:  map[[2]int32]bool{{1, 2}: true},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got arrayPacket
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, val) {
+		t.Fatalf("%+v != %+v", got, val)
+	}
+
+	// 与同样内容的切片编码相同
+	slices := slicePacket{
+		Hash:  val.Hash[:],
+		Tuple: val.Tuple[:],
+		Items: val.Items[:],
+		Empty: []int8{},
+	}
+	want, err := MarshalStruct(&slices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val.Keys = nil
+	if data, err = MarshalStruct(&val); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("%x != %x", data, want)
+	}
+
+	// 不可寻址的数组
+	e := NewBufferEncoder(nil)
+	if err := e.Encode(val.Hash, 5); err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendBytes(nil, val.Hash[:], 5); !bytes.Equal(e.Bytes(), want) {
+		t.Fatalf("%x != %x", e.Bytes(), want)
+	}
+
+	// 长度不一致
+	for _, c := range []struct {
+		modify func(p *slicePacket)
+		path   string
+	}{
+		{func(p *slicePacket) { p.Hash = p.Hash[:2] }, "arrayPacket.0"},
+		{func(p *slicePacket) { p.Tuple = p.Tuple[:3] }, "arrayPacket.1"},
+		{func(p *slicePacket) { p.Items = append(p.Items, taggedInner{Name: "c"}) }, "arrayPacket.2"},
+		{func(p *slicePacket) { p.Empty = []int8{1} }, "arrayPacket.3"},
+	} {
+		bad := slices
+		c.modify(&bad)
+		data, err := MarshalStruct(&bad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = UnmarshalStruct(data, new(arrayPacket))
+		var de *DecodeError
+		if !errors.As(err, &de) || !errors.Is(err, ErrJceDecodeArrayLength) || de.Path != c.path {
+			t.Fatalf("%s: unexpected error %v", c.path, err)
+		}
+	}
+}
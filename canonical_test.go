@@ -0,0 +1,123 @@
+package gojce
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type canonicalPacket struct {
+	Packet  RequestPacket               `tag:"0"`
+	Structs map[taggedInner]bool        `tag:"1"`
+	Arrays  map[[2]int32]string         `tag:"2"`
+	Nested  map[int64]map[string]int32  `tag:"3"`
+	Mixed   map[interface{}]int32       `tag:"4"`
+	Lists   []map[float64][]taggedInner `tag:"5"`
+}
+
+func newCanonicalPacket() *canonicalPacket {
+	p := &canonicalPacket{
+		Packet: *newBenchPacket(),
+		Structs: map[taggedInner]bool{
+			{Name: "b"}: true, {Name: "a", Score: 2}: false, {Name: "a", Score: 1}: true,
+		},
+		Arrays: map[[2]int32]string{{1, 2}: "a", {-1, 0}: "b", {300, 1}: "c", {0, 0}: "d"},
+		Nested: map[int64]map[string]int32{},
+		Mixed:  map[interface{}]int32{int32(1): 1, "a": 2, int64(1 << 40): 3, 1.5: 4},
+		Lists:  []map[float64][]taggedInner{{2: nil, 1: {{Name: "x"}}, -1: {}}},
+	}
+	p.Packet.Context = map[string]string{strings.Repeat("z", 300): "long", "": "empty"}
+	for i := 0; i < 20; i++ {
+		p.Packet.Context[strconv.Itoa(i*7)] = strconv.Itoa(i)
+		p.Nested[int64(i*1000-5000)] = map[string]int32{strconv.Itoa(i): int32(i), "k": 0}
+	}
+	return p
+}
+
+// checkSorted 检查所有 map 的 key 按编码结果升序排列
+func checkSorted(t *testing.T, v *Value) {
+	t.Helper()
+	var prev []byte
+	for i := range v.Map {
+		key, err := appendValue(nil, 0, &v.Map[i].Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && bytes.Compare(prev, key) >= 0 {
+			t.Fatalf("map key %x not after %x", key, prev)
+		}
+		prev = key
+		checkSorted(t, &v.Map[i].Key)
+		checkSorted(t, &v.Map[i].Value)
+	}
+	for i := range v.List {
+		checkSorted(t, &v.List[i])
+	}
+	for i := range v.Fields {
+		checkSorted(t, &v.Fields[i].Value)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	want, err := MarshalCanonical(newCanonicalPacket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := NewBytesDecoder(want).DecodeAny()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range fields {
+		checkSorted(t, &fields[i].Value)
+	}
+	var sbuf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		got, err := MarshalCanonical(newCanonicalPacket())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d: %x != %x", i, got, want)
+		}
+
+		// 流式编码和 Struct 的结果相同
+		sbuf.Reset()
+		e := NewEncoderWithOptions(&sbuf, EncoderOptions{Canonical: true})
+		if err := e.Encode(newCanonicalPacket(), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sbuf.Bytes()[1:sbuf.Len()-1], want) {
+			t.Fatalf("%d: stream %x != %x", i, sbuf.Bytes(), want)
+		}
+		packet := newCanonicalPacket().Packet
+		got, err = MarshalCanonical(&packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packetWant, err := MarshalCanonical((*taggedRequestPacket)(&packet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, packetWant) {
+			t.Fatalf("%d: %x != %x", i, got, packetWant)
+		}
+	}
+
+	// 解码后再编码结果不变，interface 不支持解码
+	p := newCanonicalPacket()
+	p.Mixed = nil
+	if want, err = MarshalCanonical(p); err != nil {
+		t.Fatal(err)
+	}
+	var got canonicalPacket
+	if err := UnmarshalStruct(want, &got); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := MarshalCanonical(&got); err != nil || !bytes.Equal(again, want) {
+		t.Fatalf("%v: %x != %x", err, again, want)
+	}
+}
@@ -0,0 +1,296 @@
+// Package client 基于单个 TCP 连接、按 IRequestId 复用的 JCE RPC 客户端
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofly/gojce"
+)
+
+var (
+	// ErrClientClosed 客户端已关闭
+	ErrClientClosed = errors.New("client: client closed")
+	// ErrConnectionLost 请求发出后连接断开，请求可能已被服务端处理
+	ErrConnectionLost = errors.New("client: connection lost")
+)
+
+// Client 多个 goroutine 可并发调用，请求在同一连接上流水线发送，
+// 连接断开后下一次调用时自动重连。Client 实现了 gojce.Invoker
+type Client struct {
+	// Timeout ctx 没有截止时间时的默认超时，<= 0 表示不超时
+	Timeout time.Duration
+	// DialTimeout 建立连接的超时，<= 0 表示不超时
+	DialTimeout time.Duration
+	// MaxFrameSize 单个响应帧的最大长度，<= 0 时使用 gojce.DefaultMaxFrameSize
+	MaxFrameSize int
+
+	addr   string
+	nextID int32
+
+	// dial 建立连接，为 nil 时使用 net.Dialer，测试时替换
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu sync.Mutex
+	cc *clientConn
+	// dialing 正在建立连接时非 nil，连接建立后关闭，其他调用等待它而不重复建立
+	dialing chan struct{}
+	closed  bool
+}
+
+// New 创建连接 addr 的客户端，连接在第一次调用时建立
+func New(addr string) *Client {
+	return &Client{addr: addr}
+}
+
+// Invoke 发送请求并返回响应的 SBuffer，IRet 非 0 时返回 *gojce.RPCError；
+// 单向请求发送后立即返回 nil, nil
+func (c *Client) Invoke(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+	rsp, err := c.Call(ctx, req)
+	if err != nil || rsp == nil {
+		return nil, err
+	}
+	if rsp.IRet != gojce.ServerSuccess {
+		return nil, &gojce.RPCError{Code: rsp.IRet, Desc: rsp.SResultDesc}
+	}
+	return rsp.SBuffer, nil
+}
+
+// Call 发送请求并等待对应的响应包。req 不会被修改，IRequestId 和 ITimeout
+// 由客户端填写；单向请求不等待响应，返回 nil, nil
+func (c *Client) Call(ctx context.Context, req *gojce.RequestPacket) (*gojce.ResponsePacket, error) {
+	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	packet := *req
+	packet.IRequestId = atomic.AddInt32(&c.nextID, 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		packet.ITimeout = int32((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	oneway := packet.CPacketType == gojce.OnewayPacket
+
+	var (
+		cc  *clientConn
+		ch  chan *gojce.ResponsePacket
+		err error
+	)
+	// 复用的连接可能已被对端关闭，写失败时重连重试一次
+	for retry := 0; retry < 2; retry++ {
+		if retry > 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var fresh bool
+		cc, fresh, err = c.conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !oneway {
+			ch, err = cc.register(packet.IRequestId)
+			if err != nil {
+				continue
+			}
+		}
+		err = cc.write(ctx, &packet)
+		if err == nil {
+			break
+		}
+		cc.unregister(packet.IRequestId)
+		if fresh {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if oneway {
+		return nil, nil
+	}
+
+	select {
+	case rsp := <-ch:
+		return rsp, nil
+	case <-cc.done:
+		// 连接断开前响应可能已经到达
+		select {
+		case rsp := <-ch:
+			return rsp, nil
+		default:
+		}
+		return nil, ErrConnectionLost
+	case <-ctx.Done():
+		cc.unregister(packet.IRequestId)
+		return nil, ctx.Err()
+	}
+}
+
+// conn 返回可用的连接，必要时重新建立，fresh 表示连接是新建的。
+// 建立连接时不持有 c.mu，Close 不需要等待
+func (c *Client) conn(ctx context.Context) (cc *clientConn, fresh bool, err error) {
+	c.mu.Lock()
+	for {
+		if c.closed {
+			c.mu.Unlock()
+			return nil, false, ErrClientClosed
+		}
+		if c.cc != nil && !c.cc.broken() {
+			cc = c.cc
+			c.mu.Unlock()
+			return cc, false, nil
+		}
+		if c.dialing == nil {
+			break
+		}
+		// 等待其他调用建立的连接，失败时自己重新建立
+		dialing := c.dialing
+		c.mu.Unlock()
+		select {
+		case <-dialing:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	dialing := make(chan struct{})
+	c.dialing = dialing
+	c.mu.Unlock()
+
+	dial := c.dial
+	if dial == nil {
+		d := net.Dialer{Timeout: c.DialTimeout}
+		dial = d.DialContext
+	}
+	rwc, err := dial(ctx, "tcp", c.addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dialing = nil
+	close(dialing)
+	if err != nil {
+		return nil, false, err
+	}
+	if c.closed {
+		// 建立连接期间客户端已关闭
+		rwc.Close()
+		return nil, false, ErrClientClosed
+	}
+	c.cc = &clientConn{
+		rwc:     rwc,
+		fw:      gojce.NewFrameWriter(rwc, 0),
+		pending: make(map[int32]chan *gojce.ResponsePacket),
+		done:    make(chan struct{}),
+	}
+	go c.cc.readLoop(c.MaxFrameSize)
+	return c.cc, true, nil
+}
+
+// Close 关闭连接，等待中的调用返回 ErrConnectionLost
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.cc != nil {
+		c.cc.close()
+		c.cc = nil
+	}
+	return nil
+}
+
+// clientConn 一个 TCP 连接及其上等待响应的请求
+type clientConn struct {
+	rwc net.Conn
+	wmu sync.Mutex
+	fw  *gojce.FrameWriter
+
+	mu      sync.Mutex
+	pending map[int32]chan *gojce.ResponsePacket
+	done    chan struct{}
+	closed  bool
+}
+
+func (cc *clientConn) register(id int32) (chan *gojce.ResponsePacket, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.closed {
+		return nil, ErrConnectionLost
+	}
+	ch := make(chan *gojce.ResponsePacket, 1)
+	cc.pending[id] = ch
+	return ch, nil
+}
+
+func (cc *clientConn) unregister(id int32) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.pending, id)
+}
+
+// write 写入请求，ctx 有截止时间时作为写超时，对端不读取时不会一直阻塞；
+// 写失败时帧可能不完整，关闭连接
+func (cc *clientConn) write(ctx context.Context, req *gojce.RequestPacket) error {
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok {
+		cc.rwc.SetWriteDeadline(deadline)
+		defer cc.rwc.SetWriteDeadline(time.Time{})
+	}
+	if err := cc.fw.WriteMessage(req); err != nil {
+		cc.close()
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			// 写超时由 ctx 的截止时间引起
+			return context.DeadlineExceeded
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (cc *clientConn) broken() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.closed
+}
+
+func (cc *clientConn) close() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.closed {
+		return
+	}
+	cc.closed = true
+	cc.rwc.Close()
+	close(cc.done)
+}
+
+// readLoop 读取响应并按 IRequestId 交给等待的调用，已超时的请求的响应直接丢弃
+func (cc *clientConn) readLoop(maxFrameSize int) {
+	defer cc.close()
+	fr := gojce.NewFrameReader(bufio.NewReader(cc.rwc), maxFrameSize)
+	for {
+		rsp := new(gojce.ResponsePacket)
+		if err := fr.ReadResponse(rsp); err != nil {
+			return
+		}
+		cc.mu.Lock()
+		ch, ok := cc.pending[rsp.IRequestId]
+		delete(cc.pending, rsp.IRequestId)
+		cc.mu.Unlock()
+		if ok {
+			ch <- rsp
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofly/gojce"
+	"github.com/gofly/gojce/server"
+)
+
+func startServer(t *testing.T, addr string, handler server.DispatcherFunc) (*server.Server, string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{ErrorLog: log.New(io.Discard, "", 0)}
+	srv.Handle("Test.EchoObj", handler)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return srv, l.Addr().String()
+}
+
+func echo(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+	switch req.SFuncName {
+	case "sleep":
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+	case "fail":
+		return nil, &gojce.RPCError{Code: -100, Desc: "failed"}
+	}
+	return req.SBuffer, nil
+}
+
+func newRequest(fn string, body string) *gojce.RequestPacket {
+	return &gojce.RequestPacket{
+		IVersion:     gojce.JceVersion,
+		SServantName: "Test.EchoObj",
+		SFuncName:    fn,
+		SBuffer:      []byte(body),
+		Context:      map[string]string{},
+		Status:       map[string]string{},
+	}
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	_, addr := startServer(t, "127.0.0.1:0", echo)
+	c := New(addr)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf("call-%d", i)
+			out, err := c.Invoke(context.Background(), newRequest("echo", body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(out) != body {
+				t.Errorf("got %q, want %q", out, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	_, err := c.Invoke(context.Background(), newRequest("fail", ""))
+	var re *gojce.RPCError
+	if !errors.As(err, &re) || re.Code != -100 || re.Desc != "failed" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	var timeout int32
+	_, addr := startServer(t, "127.0.0.1:0", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		timeout = req.ITimeout
+		return echo(ctx, req)
+	})
+	c := New(addr)
+	c.Timeout = 50 * time.Millisecond
+	defer c.Close()
+
+	start := time.Now()
+	if _, err := c.Invoke(context.Background(), newRequest("sleep", "")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("timeout took %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.Invoke(ctx, newRequest("echo", "x")); err != nil {
+		t.Fatal(err)
+	}
+	if timeout <= 50 || timeout > 1000 {
+		t.Fatalf("ITimeout %d not taken from ctx deadline", timeout)
+	}
+}
+
+func TestClientOneway(t *testing.T) {
+	called := make(chan string, 1)
+	_, addr := startServer(t, "127.0.0.1:0", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		called <- string(req.SBuffer)
+		return nil, nil
+	})
+	c := New(addr)
+	defer c.Close()
+
+	req := newRequest("notify", "event")
+	req.CPacketType = gojce.OnewayPacket
+	out, err := c.Invoke(context.Background(), req)
+	if err != nil || out != nil {
+		t.Fatalf("oneway returned %v, %v", out, err)
+	}
+	select {
+	case body := <-called:
+		if body != "event" {
+			t.Fatalf("got %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("oneway call not delivered")
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	srv, addr := startServer(t, "127.0.0.1:0", echo)
+	c := New(addr)
+	defer c.Close()
+	if _, err := c.Invoke(context.Background(), newRequest("echo", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Close()
+	startServer(t, addr, echo)
+	var (
+		out []byte
+		err error
+	)
+	// 旧连接的断开可能尚未被察觉，第一次调用允许失败
+	for i := 0; i < 2; i++ {
+		if out, err = c.Invoke(context.Background(), newRequest("echo", "b")); err == nil {
+			break
+		}
+	}
+	if err != nil || string(out) != "b" {
+		t.Fatalf("reconnect failed: %q, %v", out, err)
+	}
+
+	c.Close()
+	if _, err := c.Invoke(context.Background(), newRequest("echo", "c")); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestClientCloseWhileDialing(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	peers := make(chan net.Conn, 2)
+	c := New("127.0.0.1:0")
+	c.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		close(started)
+		<-release
+		a, b := net.Pipe()
+		peers <- b
+		return a, nil
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.Invoke(context.Background(), newRequest("echo", "a"))
+			errs <- err
+		}()
+	}
+	<-started
+
+	// 建立连接期间 Close 不被阻塞
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by dial")
+	}
+
+	// 连接建立后发现客户端已关闭，关闭新连接，只建立一次连接
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, ErrClientClosed) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	peer := <-peers
+	if _, err := peer.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(peers) != 0 {
+		t.Fatal("dialed more than once")
+	}
+}
+
+func TestClientWriteTimeout(t *testing.T) {
+	// 对端不读取时写入阻塞，ctx 的截止时间作为写超时
+	c := New("127.0.0.1:0")
+	c.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		a, b := net.Pipe()
+		t.Cleanup(func() { b.Close() })
+		return a, nil
+	}
+	defer c.Close()
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := c.Invoke(ctx, newRequest("echo", "a"))
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("write not bounded by ctx deadline")
+		}
+	}
+}
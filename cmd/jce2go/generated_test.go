@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofly/gojce"
+	"github.com/gofly/gojce/cmd/jce2go/testdata/gen/base"
+	hello "github.com/gofly/gojce/cmd/jce2go/testdata/gen/test"
+	unknown "github.com/gofly/gojce/cmd/jce2go/testdata/unknown/test"
+	"github.com/gofly/gojce/idl"
+)
+
+func TestGeneratedRoundTrip(t *testing.T) {
+	var req hello.HelloReq
+	req.ResetDefault()
+	if req.Name != "adam" || req.Ratio != 1.5 || !req.Flag || req.Stamp != -1 || req.Header.Code != base.Code_OK {
+		t.Fatalf("%+v", req)
+	}
+	req.Header.TraceId = "trace"
+	req.Header.Code = base.Code_TIMEOUT
+	req.Ids = []uint32{1, 2, 3}
+	req.Extra = map[string][]byte{"k": []byte("v")}
+	var buf bytes.Buffer
+	if err := req.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got hello.HelloReq
+	if err := got.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, got) {
+		t.Fatalf("%+v != %+v", req, got)
+	}
+	if got.ClassName() != "Test.HelloReq" || len(got.MD5()) != 32 {
+		t.Fatal(got.ClassName(), got.MD5())
+	}
+}
+
+func TestGeneratedDefaults(t *testing.T) {
+	// 只有必填字段，可选字段缺失时取默认值，而不是上一次解码的值
+	header := base.Header{TraceId: "only"}
+	e := gojce.NewBufferEncoder(nil)
+	if err := e.WriteStruct(&header, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := hello.HelloReq{
+		Header: base.Header{Code: base.Code_ERROR},
+		Name:   "eve",
+		Ids:    []uint32{1},
+	}
+	if err := got.Decode(bytes.NewReader(e.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.TraceId != "only" || got.Header.Code != base.Code_OK {
+		t.Fatalf("%+v", got.Header)
+	}
+	if got.Name != "adam" || got.Ratio != 1.5 || !got.Flag || got.Stamp != -1 || len(got.Ids) != 0 {
+		t.Fatalf("%+v", got)
+	}
+}
+
+func TestGeneratedEnum(t *testing.T) {
+	if base.Code_ERROR.String() != "ERROR" || base.Code(7).String() != "7" || base.CodeValue["TIMEOUT"] != 0 {
+		t.Fatal(base.Code_ERROR, base.Code(7), base.CodeValue)
+	}
+	var e gojce.Enum = base.Code_ERROR
+	if e.Int32() != -1 {
+		t.Fatal(e.Int32())
+	}
+
+	header := base.Header{TraceId: "t", Code: 7}
+	data, err := gojce.Marshal(&header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got base.Header
+	if err := gojce.Unmarshal(data, &got); err != nil || got.Code != 7 {
+		t.Fatal(err, got)
+	}
+	err = gojce.UnmarshalWithOptions(data, &got, gojce.DecoderOptions{StrictEnums: true})
+	var de *gojce.DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, gojce.ErrJceDecodeUnknownEnum) || de.Path != "Header.1" {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneratedJSON(t *testing.T) {
+	var req hello.HelloReq
+	req.ResetDefautlt()
+	req.Header.TraceId = "t"
+	req.Header.Code = base.Code_ERROR
+	req.Ids = []uint32{1, 4294967295}
+	req.Extra = map[string][]byte{"k": []byte("v")}
+	var buf bytes.Buffer
+	if err := req.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按生成的类型转换，未出现的字段取默认值
+	typed, err := gojce.FromJSON([]byte(`{"header":{"traceId":"t","code":-1},"ids":[1,4294967295],"extra":{"k":"dg=="}}`), new(hello.HelloReq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(typed, buf.Bytes()) {
+		t.Fatalf("typed: %x != %x", typed, buf.Bytes())
+	}
+
+	// 按 IDL 转换，结果与生成代码相同
+	set, err := idl.Load("../../idl/testdata/hello.jce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := set.FromJSON("Test", "HelloReq", []byte(`{"header":{"traceId":"t","code":"ERROR"},"ids":[1,4294967295],"extra":{"k":"dg=="}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("idl: %x != %x", data, buf.Bytes())
+	}
+	js, err := set.ToJSON("Test", "HelloReq", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"header":{"traceId":"t","code":"ERROR"},"name":"adam","ids":[1,4294967295],"extra":{"k":"dg=="},"ratio":1.5,"flag":true,"stamp":-1}`
+	if string(js) != want {
+		t.Fatalf("%s != %s", js, want)
+	}
+	js, err = gojce.ToJSON(data, new(hello.HelloReq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(want, `"ERROR"`, "-1", 1); string(js) != want {
+		t.Fatalf("%s != %s", js, want)
+	}
+}
+
+type helloImpl struct {
+	pinged string
+}
+
+func (h *helloImpl) SayHello(ctx context.Context, req *hello.HelloReq, rsp *string) (int32, error) {
+	*rsp = "hello " + req.Name
+	return int32(len(req.Ids)), nil
+}
+
+func (h *helloImpl) Ping(ctx context.Context, uid string) error {
+	h.pinged = uid
+	return nil
+}
+
+// loopback 直接调用 Dispatcher 的 Invoker
+type loopback struct {
+	servant string
+	d       gojce.Dispatcher
+}
+
+func (l *loopback) Invoke(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := req.Encode(&buf); err != nil {
+		return nil, err
+	}
+	var got gojce.RequestPacket
+	if err := got.Decode(&buf); err != nil {
+		return nil, err
+	}
+	if got.SServantName != l.servant {
+		return nil, errors.New("unknown servant " + got.SServantName)
+	}
+	return l.d.Dispatch(ctx, &got)
+}
+
+func TestGeneratedRPC(t *testing.T) {
+	impl := &helloImpl{}
+	proxy := hello.NewHelloProxy("Test.HelloServer.HelloObj", &loopback{
+		servant: "Test.HelloServer.HelloObj",
+		d:       hello.NewHelloDispatcher(impl),
+	})
+	var req hello.HelloReq
+	req.ResetDefautlt()
+	req.Ids = []uint32{1, 2}
+	var rsp string
+	ret, err := proxy.SayHello(context.Background(), &req, &rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 2 || rsp != "hello adam" {
+		t.Fatal(ret, rsp)
+	}
+	if err := proxy.Ping(context.Background(), "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if impl.pinged != "u1" {
+		t.Fatal(impl.pinged)
+	}
+
+	_, err = hello.NewHelloDispatcher(impl).Dispatch(context.Background(), &gojce.RequestPacket{SFuncName: "missing"})
+	if !errors.Is(err, gojce.ErrServantFuncNotFound) {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneratedProxyNilParams(t *testing.T) {
+	proxy := hello.NewHelloProxy("Test.HelloServer.HelloObj", &loopback{
+		servant: "Test.HelloServer.HelloObj",
+		d:       hello.NewHelloDispatcher(&helloImpl{}),
+	})
+	var (
+		req hello.HelloReq
+		rsp string
+	)
+	// 结构体参数和 out 参数为 nil 时返回错误
+	cases := []struct {
+		req *hello.HelloReq
+		rsp *string
+		err string
+	}{
+		{nil, &rsp, "sayHello: nil parameter req"},
+		{&req, nil, "sayHello: nil parameter rsp"},
+	}
+	for _, c := range cases {
+		if _, err := proxy.SayHello(context.Background(), c.req, c.rsp); err == nil || err.Error() != c.err {
+			t.Fatalf("unexpected error %v, want %q", err, c.err)
+		}
+	}
+}
+
+func TestGeneratedUnknownFields(t *testing.T) {
+	var req unknown.HelloReq
+	req.ResetDefautlt()
+	req.Header.TraceId = "trace"
+	req.Ids = []uint32{1, 2}
+	data, err := gojce.Marshal(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := gojce.NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 在 header 末尾、tag 5 与 16 之间以及最后加入新版本的字段
+	str := gojce.Value{Type: gojce.String1, Bytes: []byte("new")}
+	header := &fields[0].Value
+	header.Fields = append(header.Fields, gojce.Field{Tag: 3, Value: str})
+	var withNew []gojce.Field
+	for _, f := range fields {
+		if f.Tag == 16 {
+			withNew = append(withNew, gojce.Field{Tag: 6, Value: str})
+		}
+		withNew = append(withNew, f)
+	}
+	withNew = append(withNew, gojce.Field{Tag: 20, Value: gojce.Value{Type: gojce.Int32, Int: 1 << 20}})
+	data, err = gojce.AppendAny(nil, withNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got unknown.HelloReq
+	if err := gojce.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.UnknownFields) == 0 || len(got.Header.UnknownFields) == 0 || got.Header.TraceId != "trace" {
+		t.Fatalf("%+v", got)
+	}
+	out, err := gojce.Marshal(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("%x != %x", out, data)
+	}
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofly/gojce/idl"
+)
+
+const gojcePath = "github.com/gofly/gojce"
+
+// Generator 根据 IDL 生成 Go 代码，每个 module 对应一个 Go 包
+type Generator struct {
+	// ModulePath 输出目录对应的 import 路径，跨 module 引用时使用
+	ModulePath string
+	// UnknownFields 为结构体生成 UnknownFields 字段，解码时保存未知的 tag 并在编码时写回
+	UnknownFields bool
+
+	set *idl.Set
+}
+
+// Generate 解析 filename 并生成代码，返回相对输出目录的文件路径到内容的映射
+func (g *Generator) Generate(filename string, recursive bool, includeDirs ...string) (map[string][]byte, error) {
+	set, err := idl.Load(filename, includeDirs...)
+	if err != nil {
+		return nil, err
+	}
+	g.set = set
+	files := set.Files
+	if !recursive {
+		// 入口文件总是排在最后
+		files = files[len(files)-1:]
+	}
+	out := make(map[string][]byte)
+	for _, f := range files {
+		var order []string
+		modules := make(map[string][]*idl.Module)
+		for _, m := range f.Modules {
+			if _, ok := modules[m.Name]; !ok {
+				order = append(order, m.Name)
+			}
+			modules[m.Name] = append(modules[m.Name], m)
+		}
+		base := strings.TrimSuffix(filepath.Base(f.Name), filepath.Ext(f.Name))
+		for _, name := range order {
+			content, err := g.generateModule(f, name, modules[name])
+			if err != nil {
+				return nil, err
+			}
+			out[filepath.Join(packageName(name), base+".go")] = content
+		}
+	}
+	return out, nil
+}
+
+// packageName module 名对应的 Go 包名
+func packageName(module string) string {
+	return strings.ToLower(module)
+}
+
+// goName 转换为导出的 Go 标识符
+func goName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// reservedFieldNames 与生成方法冲突的字段名
+var reservedFieldNames = map[string]bool{
+	"Encode": true, "Decode": true, "ClassName": true, "MD5": true, "ResetDefault": true, "ResetDefautlt": true,
+}
+
+func fieldName(name string) string {
+	n := goName(name)
+	if reservedFieldNames[n] {
+		n += "_"
+	}
+	return n
+}
+
+type fileWriter struct {
+	g       *Generator
+	scope   string
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (w *fileWriter) P(format string, args ...interface{}) {
+	fmt.Fprintf(&w.buf, format, args...)
+	w.buf.WriteByte('\n')
+}
+
+func (w *fileWriter) errorf(pos idl.Pos, format string, args ...interface{}) error {
+	return &idl.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (g *Generator) generateModule(f *idl.File, name string, modules []*idl.Module) ([]byte, error) {
+	w := &fileWriter{g: g, scope: name, imports: make(map[string]bool)}
+	for _, m := range modules {
+		if err := w.module(m); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by jce2go. DO NOT EDIT.\n// source: %s\n\n", filepath.Base(f.Name))
+	fmt.Fprintf(&out, "package %s\n\n", packageName(name))
+	if len(w.imports) > 0 {
+		paths := make([]string, 0, len(w.imports))
+		for path := range w.imports {
+			paths = append(paths, path)
+		}
+		// 标准库在前，第三方包在后
+		sort.Slice(paths, func(i, j int) bool {
+			si, sj := isStdPackage(paths[i]), isStdPackage(paths[j])
+			if si != sj {
+				return si
+			}
+			return paths[i] < paths[j]
+		})
+		out.WriteString("import (\n")
+		for i, path := range paths {
+			if i > 0 && isStdPackage(paths[i-1]) && !isStdPackage(path) {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(w.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code for %s: %v", f.Name, err)
+	}
+	return src, nil
+}
+
+func isStdPackage(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+func (w *fileWriter) use(path string) string {
+	w.imports[path] = true
+	return filepath.Base(path)
+}
+
+func (w *fileWriter) module(m *idl.Module) error {
+	if len(m.Consts) > 0 {
+		w.P("const (")
+		for _, c := range m.Consts {
+			t, err := w.goType(c.Type)
+			if err != nil {
+				return err
+			}
+			w.P("%s %s = %s", goName(c.Name), t, literal(c.Type, c.Value))
+		}
+		w.P(")")
+		w.P("")
+	}
+	for _, e := range m.Enums {
+		w.enum(m, e)
+	}
+	for _, s := range m.Structs {
+		if err := w.structType(m, s); err != nil {
+			return err
+		}
+	}
+	for _, i := range m.Interfaces {
+		if err := w.iface(m, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *fileWriter) enum(m *idl.Module, e *idl.Enum) {
+	w.use("strconv")
+	w.P("// %s %s::%s", e.Name, m.Name, e.Name)
+	w.P("type %s int32", e.Name)
+	w.P("")
+	w.P("const (")
+	for _, member := range e.Members {
+		w.P("%s_%s %s = %d", e.Name, member.Name, e.Name, member.Value)
+	}
+	w.P(")")
+	w.P("")
+	w.P("// %sName %s 的取值对应的名字", e.Name, e.Name)
+	w.P("var %sName = map[int32]string{", e.Name)
+	seen := make(map[int32]bool)
+	for _, member := range e.Members {
+		// 取值相同的成员只保留第一个名字
+		if !seen[member.Value] {
+			seen[member.Value] = true
+			w.P("%d: %q,", member.Value, member.Name)
+		}
+	}
+	w.P("}")
+	w.P("")
+	w.P("// %sValue %s 的名字对应的取值", e.Name, e.Name)
+	w.P("var %sValue = map[string]int32{", e.Name)
+	for _, member := range e.Members {
+		w.P("%q: %d,", member.Name, member.Value)
+	}
+	w.P("}")
+	w.P("")
+	w.P("func (x %s) Int32() int32 {", e.Name)
+	w.P("return int32(x)")
+	w.P("}")
+	w.P("")
+	w.P("func (x %s) String() string {", e.Name)
+	w.P("if name, ok := %sName[int32(x)]; ok {", e.Name)
+	w.P("return name")
+	w.P("}")
+	w.P("return strconv.Itoa(int(x))")
+	w.P("}")
+	w.P("")
+	w.P("// IsValid 是否是声明过的取值")
+	w.P("func (x %s) IsValid() bool {", e.Name)
+	w.P("_, ok := %sName[int32(x)]", e.Name)
+	w.P("return ok")
+	w.P("}")
+	w.P("")
+}
+
+// literal 常量值的 Go 字面量
+func literal(t *idl.Type, v *idl.Value) string {
+	switch v.Kind {
+	case idl.StringValue:
+		return strconv.Quote(v.Str)
+	case idl.FloatValue:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case idl.BoolValue:
+		return strconv.FormatBool(v.Bool)
+	}
+	return v.Text
+}
+
+// resolve 解析自定义类型所在的 module 以及结构体或枚举定义
+func (w *fileWriter) resolve(t *idl.Type) (string, *idl.Struct, *idl.Enum, error) {
+	if m, s := w.g.set.LookupStruct(w.scope, t.Name); s != nil {
+		return m.Name, s, nil, nil
+	}
+	if m, e := w.g.set.LookupEnum(w.scope, t.Name); e != nil {
+		return m.Name, nil, e, nil
+	}
+	return "", nil, nil, w.errorf(t.Pos, "undefined type %s", t.Name)
+}
+
+// qualify 返回其他 module 中类型的限定名，并记录 import
+func (w *fileWriter) qualify(module, name string) (string, error) {
+	if module == w.scope {
+		return name, nil
+	}
+	if w.g.ModulePath == "" {
+		return "", fmt.Errorf("type %s::%s is defined in another module, -module-path is required", module, name)
+	}
+	return w.use(w.g.ModulePath+"/"+packageName(module)) + "." + name, nil
+}
+
+func (w *fileWriter) goType(t *idl.Type) (string, error) {
+	switch t.Kind {
+	case idl.Bool:
+		return "bool", nil
+	case idl.Byte:
+		if t.Unsigned {
+			return "uint8", nil
+		}
+		return "int8", nil
+	case idl.Short:
+		if t.Unsigned {
+			return "uint16", nil
+		}
+		return "int16", nil
+	case idl.Int:
+		if t.Unsigned {
+			return "uint32", nil
+		}
+		return "int32", nil
+	case idl.Long:
+		return "int64", nil
+	case idl.Float:
+		return "float32", nil
+	case idl.Double:
+		return "float64", nil
+	case idl.String:
+		return "string", nil
+	case idl.Vector:
+		if t.Elem.Kind == idl.Byte {
+			return "[]byte", nil
+		}
+		elem, err := w.goType(t.Elem)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case idl.Map:
+		if !w.comparable(t.Key) {
+			return "", w.errorf(t.Pos, "map key type %s is not comparable in Go", t.Key)
+		}
+		key, err := w.goType(t.Key)
+		if err != nil {
+			return "", err
+		}
+		elem, err := w.goType(t.Elem)
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + elem, nil
+	case idl.Named:
+		module, s, e, err := w.resolve(t)
+		if err != nil {
+			return "", err
+		}
+		if s != nil {
+			return w.qualify(module, s.Name)
+		}
+		return w.qualify(module, e.Name)
+	}
+	return "", w.errorf(t.Pos, "unsupported type %s", t)
+}
+
+func (w *fileWriter) comparable(t *idl.Type) bool {
+	switch t.Kind {
+	case idl.Vector, idl.Map:
+		return false
+	case idl.Named:
+		module, s, _, err := w.resolve(t)
+		if err != nil || s == nil {
+			return err == nil
+		}
+		scope := w.scope
+		w.scope = module
+		defer func() { w.scope = scope }()
+		for _, f := range s.Fields {
+			if !w.comparable(f.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canonical 结构体定义的规范化文本，用于计算 MD5
+func (w *fileWriter) canonical(m *idl.Module, s *idl.Struct) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "struct %s::%s{", m.Name, s.Name)
+	for _, f := range s.Fields {
+		kind := "optional"
+		if f.Required {
+			kind = "require"
+		}
+		fmt.Fprintf(&sb, "%d %s %s %s", f.Tag, kind, w.canonicalType(f.Type), f.Name)
+		if f.Default != nil {
+			fmt.Fprintf(&sb, "=%s", f.Default.Text)
+		}
+		sb.WriteString(";")
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (w *fileWriter) canonicalType(t *idl.Type) string {
+	switch t.Kind {
+	case idl.Vector:
+		return "vector<" + w.canonicalType(t.Elem) + ">"
+	case idl.Map:
+		return "map<" + w.canonicalType(t.Key) + "," + w.canonicalType(t.Elem) + ">"
+	case idl.Named:
+		module, _, _, err := w.resolve(t)
+		if err != nil {
+			return t.Name
+		}
+		name := t.Name
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			name = name[i+2:]
+		}
+		return module + "::" + name
+	}
+	return t.String()
+}
+
+func (w *fileWriter) structType(m *idl.Module, s *idl.Struct) error {
+	names := make([]string, len(s.Fields))
+	types := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		t, err := w.goType(f.Type)
+		if err != nil {
+			return err
+		}
+		names[i], types[i] = fieldName(f.Name), t
+		if w.g.UnknownFields && names[i] == "UnknownFields" {
+			return w.errorf(f.Pos, "field %s conflicts with generated UnknownFields", f.Name)
+		}
+	}
+	gojce := w.use(gojcePath)
+	w.use("io")
+
+	w.P("// %s %s::%s", s.Name, m.Name, s.Name)
+	w.P("type %s struct {", s.Name)
+	for i, f := range s.Fields {
+		tags := fmt.Sprintf("tag:\"%d\"", f.Tag)
+		if f.Required {
+			tags += " required:\"true\""
+		}
+		tags += fmt.Sprintf(" json:%q", f.Name)
+		w.P("%s %s `%s`", names[i], types[i], tags)
+	}
+	if w.g.UnknownFields {
+		w.P("UnknownFields %s.UnknownFields `json:\"-\"`", gojce)
+	}
+	w.P("}")
+	w.P("")
+
+	sum := md5.Sum([]byte(w.canonical(m, s)))
+	w.P("func (p *%s) ClassName() string {", s.Name)
+	w.P("return %q", m.Name+"."+s.Name)
+	w.P("}")
+	w.P("")
+	w.P("func (p *%s) MD5() string {", s.Name)
+	w.P("return %q", hex.EncodeToString(sum[:]))
+	w.P("}")
+	w.P("")
+
+	w.P("func (p *%s) ResetDefault() {", s.Name)
+	w.P("*p = %s{}", s.Name)
+	for i, f := range s.Fields {
+		if err := w.resetField(names[i], types[i], f); err != nil {
+			return err
+		}
+	}
+	w.P("}")
+	w.P("")
+	w.P("// ResetDefautlt 同 ResetDefault，实现 %s.Message 接口", gojce)
+	w.P("func (p *%s) ResetDefautlt() {", s.Name)
+	w.P("p.ResetDefault()")
+	w.P("}")
+	w.P("")
+
+	w.P("func (p *%s) Encode(w io.Writer) error {", s.Name)
+	w.P("var err error")
+	w.P("encoder := %s.NewEncoder(w)", gojce)
+	if w.g.UnknownFields {
+		w.P("unknown := p.UnknownFields")
+	}
+	for i, f := range s.Fields {
+		if w.g.UnknownFields {
+			w.P("unknown, err = encoder.WriteUnknownBefore(unknown, %d)", f.Tag)
+			w.P("if nil != err {")
+			w.P("return err")
+			w.P("}")
+		}
+		if err := w.writeField("p."+names[i], f.Tag, f.Type, "return err"); err != nil {
+			return err
+		}
+	}
+	if w.g.UnknownFields {
+		w.P("err = encoder.WriteUnknown(unknown)")
+		w.P("if nil != err {")
+		w.P("return err")
+		w.P("}")
+	}
+	w.P("return encoder.Flush()")
+	w.P("}")
+	w.P("")
+
+	w.P("func (p *%s) Decode(r io.Reader) error {", s.Name)
+	w.P("var err error")
+	w.P("decoder := %s.NewDecoder(r)", gojce)
+	w.P("p.ResetDefault()")
+	if w.g.UnknownFields {
+		w.P("decoder.CaptureUnknown(&p.UnknownFields)")
+	}
+	for _, f := range s.Fields {
+		if keepDefault(f) {
+			w.P("var has bool")
+			break
+		}
+	}
+	for i, f := range s.Fields {
+		if keepDefault(f) {
+			// ReadXxx 在字段不存在时写入零值，有默认值的可选字段先判断是否存在
+			w.P("has, err = decoder.HasField(%d)", f.Tag)
+			w.P("if nil != err {")
+			w.P("return err")
+			w.P("}")
+			w.P("if has {")
+		}
+		if err := w.readField("p."+names[i], f.Tag, f.Required, f.Type, "return err"); err != nil {
+			return err
+		}
+		if keepDefault(f) {
+			w.P("}")
+		}
+	}
+	if w.g.UnknownFields {
+		w.P("err = decoder.ReadUnknown()")
+	}
+	w.P("return err")
+	w.P("}")
+	w.P("")
+	return nil
+}
+
+// keepDefault 字段不存在时是否需要保留 ResetDefault 设置的默认值
+func keepDefault(f *idl.Field) bool {
+	return !f.Required && f.Default != nil
+}
+
+func (w *fileWriter) resetField(name, goType string, f *idl.Field) error {
+	if f.Type.Kind == idl.Named {
+		_, s, e, err := w.resolve(f.Type)
+		if err != nil {
+			return err
+		}
+		if s != nil {
+			w.P("p.%s.ResetDefault()", name)
+			return nil
+		}
+		if f.Default == nil {
+			return nil
+		}
+		if f.Default.Kind == idl.IntValue {
+			w.P("p.%s = %s(%s)", name, goType, f.Default.Text)
+			return nil
+		}
+		member := f.Default.Str
+		if i := strings.LastIndex(member, "::"); i >= 0 {
+			member = member[i+2:]
+		}
+		for _, em := range e.Members {
+			if em.Name == member {
+				w.P("p.%s = %s", name, strings.TrimSuffix(goType, e.Name)+e.Name+"_"+em.Name)
+				return nil
+			}
+		}
+		return w.errorf(f.Default.Pos, "undefined enum member %s of %s", f.Default.Str, e.Name)
+	}
+	if f.Default != nil {
+		w.P("p.%s = %s", name, literal(f.Type, f.Default))
+	}
+	return nil
+}
+
+// addr 取表达式的地址，`*x` 直接返回 `x`
+func addr(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return expr[1:]
+	}
+	return "&" + expr
+}
+
+// writeStmt 生成编码 expr 的 WriteXxx 调用，expr 必须可寻址
+func (w *fileWriter) writeStmt(expr string, tag int, t *idl.Type) (string, error) {
+	switch t.Kind {
+	case idl.Bool:
+		return fmt.Sprintf("encoder.WriteBool(%s, %d)", expr, tag), nil
+	case idl.Byte, idl.Short, idl.Int, idl.Long, idl.Float, idl.Double, idl.String:
+		return fmt.Sprintf("encoder.Write%s(%s, %d)", scalarMethod(t), expr, tag), nil
+	case idl.Vector:
+		switch t.Elem.Kind {
+		case idl.Byte:
+			return fmt.Sprintf("encoder.WriteBytes(%s, %d)", expr, tag), nil
+		case idl.String:
+			return fmt.Sprintf("encoder.WriteStrings(%s, %d)", expr, tag), nil
+		}
+		return fmt.Sprintf("encoder.WriteVector(%s, %d)", expr, tag), nil
+	case idl.Map:
+		return fmt.Sprintf("encoder.WriteMap(%s, %d)", expr, tag), nil
+	case idl.Named:
+		_, s, _, err := w.resolve(t)
+		if err != nil {
+			return "", err
+		}
+		if s != nil {
+			return fmt.Sprintf("encoder.WriteStruct(%s, %d)", addr(expr), tag), nil
+		}
+		return fmt.Sprintf("encoder.WriteInt32(int32(%s), %d)", expr, tag), nil
+	}
+	return "", w.errorf(t.Pos, "unsupported type %s", t)
+}
+
+// writeField 生成编码语句并检查错误，onErr 为出错时的 return 语句
+func (w *fileWriter) writeField(expr string, tag int, t *idl.Type, onErr string) error {
+	stmt, err := w.writeStmt(expr, tag, t)
+	if err != nil {
+		return err
+	}
+	w.P("err = %s", stmt)
+	w.P("if nil != err {")
+	w.P("%s", onErr)
+	w.P("}")
+	return nil
+}
+
+// readField 生成解码 expr 的 ReadXxx 调用并检查错误，expr 必须可寻址
+func (w *fileWriter) readField(expr string, tag int, required bool, t *idl.Type, onErr string) error {
+	var stmt string
+	switch t.Kind {
+	case idl.Bool:
+		stmt = fmt.Sprintf("decoder.ReadBool(%s, %d, %t)", addr(expr), tag, required)
+	case idl.Byte, idl.Short, idl.Int, idl.Long, idl.Float, idl.Double, idl.String:
+		stmt = fmt.Sprintf("decoder.Read%s(%s, %d, %t)", scalarMethod(t), addr(expr), tag, required)
+	case idl.Vector:
+		switch t.Elem.Kind {
+		case idl.Byte:
+			stmt = fmt.Sprintf("decoder.ReadBytes(%s, %d, %t)", addr(expr), tag, required)
+		case idl.String:
+			stmt = fmt.Sprintf("decoder.ReadStrings(%s, %d, %t)", addr(expr), tag, required)
+		default:
+			stmt = fmt.Sprintf("decoder.ReadVector(%s, %d, %t)", addr(expr), tag, required)
+		}
+	case idl.Map:
+		stmt = fmt.Sprintf("decoder.ReadMap(%s, %d, %t)", addr(expr), tag, required)
+	case idl.Named:
+		_, s, _, err := w.resolve(t)
+		if err != nil {
+			return err
+		}
+		if s != nil {
+			stmt = fmt.Sprintf("decoder.ReadStruct(%s, %d, %t)", addr(expr), tag, required)
+			break
+		}
+		stmt = fmt.Sprintf("%s.ReadEnum(decoder, %s, %d, %t)", w.use(gojcePath), addr(expr), tag, required)
+	default:
+		return w.errorf(t.Pos, "unsupported type %s", t)
+	}
+	w.P("err = %s", stmt)
+	w.P("if nil != err {")
+	w.P("%s", onErr)
+	w.P("}")
+	return nil
+}
+
+// scalarMethod WriteXxx/ReadXxx 中的类型名
+func scalarMethod(t *idl.Type) string {
+	switch t.Kind {
+	case idl.Byte:
+		if t.Unsigned {
+			return "Uint8"
+		}
+		return "Int8"
+	case idl.Short:
+		if t.Unsigned {
+			return "Uint16"
+		}
+		return "Int16"
+	case idl.Int:
+		if t.Unsigned {
+			return "Uint32"
+		}
+		return "Int32"
+	case idl.Long:
+		return "Int64"
+	case idl.Float:
+		return "Float32"
+	case idl.Double:
+		return "Float64"
+	}
+	return "String"
+}
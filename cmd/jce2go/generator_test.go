@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files under testdata")
+
+// 生成的代码提交在 testdata 下，由 generated_test.go 直接编译和测试；
+// 修改生成器后用 go test -update 重新生成
+func TestGenerate(t *testing.T) {
+	cases := []struct {
+		dir string
+		g   *Generator
+	}{
+		{"gen", &Generator{}},
+		{"unknown", &Generator{UnknownFields: true}},
+	}
+	for _, c := range cases {
+		c.g.ModulePath = "github.com/gofly/gojce/cmd/jce2go/testdata/" + c.dir
+		files, err := c.g.Generate("../../idl/testdata/hello.jce", true)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dir, err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("%s: %d files", c.dir, len(files))
+		}
+		for path, content := range files {
+			golden := filepath.Join("testdata", c.dir, path)
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, content, 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, want) {
+				t.Errorf("%s differs from generated code, run go test -update", golden)
+			}
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		g   *Generator
+		err string
+	}{
+		{&Generator{}, "-module-path is required"},
+	}
+	for _, c := range cases {
+		_, err := c.g.Generate("../../idl/testdata/hello.jce", false)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("unexpected error %v, want %q", err, c.err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// jce2go 根据 .jce 文件生成 gojce 编解码代码
+//
+// 用法:
+//
+//	jce2go [-outdir dir] [-module-path path] [-I dir] [-recursive] [-unknown-fields] file.jce...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type includeDirs []string
+
+func (d *includeDirs) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *includeDirs) Set(s string) error {
+	*d = append(*d, s)
+	return nil
+}
+
+func main() {
+	var (
+		outdir     = flag.String("outdir", ".", "output directory, one sub directory per module")
+		modulePath = flag.String("module-path", "", "import path of outdir, required when modules reference each other")
+		recursive  = flag.Bool("recursive", false, "also generate code for #include files")
+		unknown    = flag.Bool("unknown-fields", false, "preserve unknown tags across decode/encode in an UnknownFields field")
+		includes   includeDirs
+	)
+	flag.Var(&includes, "I", "include search directory, can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jce2go [flags] file.jce...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	g := &Generator{ModulePath: *modulePath, UnknownFields: *unknown}
+	for _, name := range flag.Args() {
+		files, err := g.Generate(name, *recursive, includes...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for path, content := range files {
+			path = filepath.Join(*outdir, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+
+	"github.com/gofly/gojce/idl"
+)
+
+// reservedParamNames 生成的方法体中使用的局部变量名
+var reservedParamNames = map[string]bool{
+	"ctx": true, "err": true, "ret": true, "buf": true, "in": true, "out": true,
+	"encoder": true, "decoder": true, "packet": true, "p": true, "d": true, "v": true,
+}
+
+func paramName(name string) string {
+	if token.IsKeyword(name) || reservedParamNames[name] {
+		return name + "_"
+	}
+	return name
+}
+
+type param struct {
+	*idl.Param
+	name   string
+	goType string
+	isPtr  bool
+	tag    int
+}
+
+// params 解析参数的 Go 类型，结构体入参和所有出参都使用指针
+func (w *fileWriter) params(op *idl.Operation) ([]param, error) {
+	ps := make([]param, len(op.Params))
+	for i, p := range op.Params {
+		t, err := w.goType(p.Type)
+		if err != nil {
+			return nil, err
+		}
+		isPtr := p.Out
+		if !isPtr && p.Type.Kind == idl.Named {
+			_, s, _, err := w.resolve(p.Type)
+			if err != nil {
+				return nil, err
+			}
+			isPtr = s != nil
+		}
+		ps[i] = param{Param: p, name: paramName(p.Name), goType: t, isPtr: isPtr, tag: i + 1}
+	}
+	return ps, nil
+}
+
+// signature 生成 `(ctx context.Context, a T, b *U) (int32, error)` 形式的签名
+func (w *fileWriter) signature(op *idl.Operation, ps []param, named bool) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("(ctx context.Context")
+	for _, p := range ps {
+		sb.WriteString(", " + p.name + " ")
+		if p.isPtr {
+			sb.WriteString("*")
+		}
+		sb.WriteString(p.goType)
+	}
+	sb.WriteString(") ")
+	if op.Return.Kind == idl.Void {
+		if named {
+			sb.WriteString("(err error)")
+		} else {
+			sb.WriteString("error")
+		}
+		return sb.String(), nil
+	}
+	ret, err := w.goType(op.Return)
+	if err != nil {
+		return "", err
+	}
+	if named {
+		sb.WriteString("(ret " + ret + ", err error)")
+	} else {
+		sb.WriteString("(" + ret + ", error)")
+	}
+	return sb.String(), nil
+}
+
+func hasOutput(op *idl.Operation) bool {
+	if op.Return.Kind != idl.Void {
+		return true
+	}
+	for _, p := range op.Params {
+		if p.Out {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fileWriter) iface(m *idl.Module, i *idl.Interface) error {
+	gojce := w.use(gojcePath)
+	w.use("bytes")
+	w.use("context")
+	w.use("fmt")
+
+	// 服务端接口
+	w.P("// %sServant %s::%s 服务端实现的接口", i.Name, m.Name, i.Name)
+	w.P("type %sServant interface {", i.Name)
+	for _, op := range i.Operations {
+		ps, err := w.params(op)
+		if err != nil {
+			return err
+		}
+		sig, err := w.signature(op, ps, false)
+		if err != nil {
+			return err
+		}
+		w.P("%s%s", goName(op.Name), sig)
+	}
+	w.P("}")
+	w.P("")
+
+	// 客户端代理
+	w.P("// %sProxy %s::%s 客户端代理", i.Name, m.Name, i.Name)
+	w.P("type %sProxy struct {", i.Name)
+	w.P("servant string")
+	w.P("invoker %s.Invoker", gojce)
+	w.P("}")
+	w.P("")
+	w.P("func New%sProxy(servant string, invoker %s.Invoker) *%sProxy {", i.Name, gojce, i.Name)
+	w.P("return &%sProxy{servant: servant, invoker: invoker}", i.Name)
+	w.P("}")
+	w.P("")
+	for _, op := range i.Operations {
+		if err := w.proxyMethod(i, op); err != nil {
+			return err
+		}
+	}
+
+	// 服务端分发
+	w.P("// %sDispatcher 按 SFuncName 将请求分发给 %sServant", i.Name, i.Name)
+	w.P("type %sDispatcher struct {", i.Name)
+	w.P("impl %sServant", i.Name)
+	w.P("}")
+	w.P("")
+	w.P("func New%sDispatcher(impl %sServant) *%sDispatcher {", i.Name, i.Name, i.Name)
+	w.P("return &%sDispatcher{impl: impl}", i.Name)
+	w.P("}")
+	w.P("")
+	w.P("func (d *%sDispatcher) Dispatch(ctx context.Context, req *%s.RequestPacket) ([]byte, error) {", i.Name, gojce)
+	w.P("switch req.SFuncName {")
+	for _, op := range i.Operations {
+		w.P("case %q:", op.Name)
+		w.P("return d.%s(ctx, req.SBuffer)", dispatchMethod(op))
+	}
+	w.P("}")
+	w.P("return nil, fmt.Errorf(\"%%w: %%s.%%s\", %s.ErrServantFuncNotFound, req.SServantName, req.SFuncName)", gojce)
+	w.P("}")
+	w.P("")
+	for _, op := range i.Operations {
+		if err := w.dispatchOperation(i, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dispatchMethod(op *idl.Operation) string {
+	return "dispatch" + goName(op.Name)
+}
+
+func (w *fileWriter) proxyMethod(i *idl.Interface, op *idl.Operation) error {
+	ps, err := w.params(op)
+	if err != nil {
+		return err
+	}
+	sig, err := w.signature(op, ps, true)
+	if err != nil {
+		return err
+	}
+	gojce := w.use(gojcePath)
+	w.P("func (p *%sProxy) %s%s {", i.Name, goName(op.Name), sig)
+	for _, p := range ps {
+		// 结构体参数和 out 参数为 nil 时在发送请求前返回错误，不在生成的代码中 panic
+		if p.isPtr {
+			w.P("if nil == %s {", p.name)
+			w.P("err = %s.New(%q)", w.use("errors"), op.Name+": nil parameter "+p.name)
+			w.P("return")
+			w.P("}")
+		}
+	}
+	w.P("buf := new(bytes.Buffer)")
+	w.P("encoder := %s.NewEncoder(buf)", gojce)
+	for _, p := range ps {
+		if p.Out {
+			continue
+		}
+		expr := p.name
+		if p.isPtr {
+			expr = "*" + p.name
+		}
+		if err := w.writeField(expr, p.tag, p.Type, "return"); err != nil {
+			return err
+		}
+	}
+	w.P("err = encoder.Flush()")
+	w.P("if nil != err {")
+	w.P("return")
+	w.P("}")
+	w.P("packet := &%s.RequestPacket{", gojce)
+	w.P("IVersion: 1,")
+	w.P("SServantName: p.servant,")
+	w.P("SFuncName: %q,", op.Name)
+	w.P("SBuffer: buf.Bytes(),")
+	w.P("}")
+	if !hasOutput(op) {
+		w.P("_, err = p.invoker.Invoke(ctx, packet)")
+		w.P("return")
+		w.P("}")
+		w.P("")
+		return nil
+	}
+	w.P("out, err := p.invoker.Invoke(ctx, packet)")
+	w.P("if nil != err {")
+	w.P("return")
+	w.P("}")
+	w.P("decoder := %s.NewDecoder(bytes.NewReader(out))", gojce)
+	if op.Return.Kind != idl.Void {
+		if err := w.readField("ret", 0, true, op.Return, "return"); err != nil {
+			return err
+		}
+	}
+	for _, p := range ps {
+		if !p.Out {
+			continue
+		}
+		if err := w.readField("*"+p.name, p.tag, true, p.Type, "return"); err != nil {
+			return err
+		}
+	}
+	w.P("return")
+	w.P("}")
+	w.P("")
+	return nil
+}
+
+func (w *fileWriter) dispatchOperation(i *idl.Interface, op *idl.Operation) error {
+	ps, err := w.params(op)
+	if err != nil {
+		return err
+	}
+	gojce := w.use(gojcePath)
+	w.P("func (d *%sDispatcher) %s(ctx context.Context, in []byte) ([]byte, error) {", i.Name, dispatchMethod(op))
+	w.P("var err error")
+	hasInput := false
+	for _, p := range ps {
+		w.P("var %s %s", p.name, p.goType)
+		hasInput = hasInput || !p.Out
+	}
+	if hasInput {
+		w.P("decoder := %s.NewDecoder(bytes.NewReader(in))", gojce)
+		for _, p := range ps {
+			if p.Out {
+				continue
+			}
+			if err := w.readField(p.name, p.tag, true, p.Type, "return nil, err"); err != nil {
+				return err
+			}
+		}
+	}
+	args := []string{"ctx"}
+	for _, p := range ps {
+		if p.isPtr {
+			args = append(args, "&"+p.name)
+		} else {
+			args = append(args, p.name)
+		}
+	}
+	call := "d.impl." + goName(op.Name) + "(" + strings.Join(args, ", ") + ")"
+	if op.Return.Kind == idl.Void {
+		w.P("err = %s", call)
+	} else {
+		w.P("ret, err := %s", call)
+	}
+	w.P("if nil != err {")
+	w.P("return nil, err")
+	w.P("}")
+	if !hasOutput(op) {
+		w.P("return nil, nil")
+		w.P("}")
+		w.P("")
+		return nil
+	}
+	w.P("buf := new(bytes.Buffer)")
+	w.P("encoder := %s.NewEncoder(buf)", gojce)
+	if op.Return.Kind != idl.Void {
+		if err := w.writeField("ret", 0, op.Return, "return nil, err"); err != nil {
+			return err
+		}
+	}
+	for _, p := range ps {
+		if !p.Out {
+			continue
+		}
+		if err := w.writeField(p.name, p.tag, p.Type, "return nil, err"); err != nil {
+			return err
+		}
+	}
+	w.P("err = encoder.Flush()")
+	w.P("if nil != err {")
+	w.P("return nil, err")
+	w.P("}")
+	w.P("return buf.Bytes(), nil")
+	w.P("}")
+	w.P("")
+	return nil
+}
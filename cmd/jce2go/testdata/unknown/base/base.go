@@ -0,0 +1,126 @@
+// Code generated by jce2go. DO NOT EDIT.
+// source: base.jce
+
+package base
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gofly/gojce"
+)
+
+// Code Base::Code
+type Code int32
+
+const (
+	Code_OK      Code = 0
+	Code_ERROR   Code = -1
+	Code_TIMEOUT Code = 0
+)
+
+// CodeName Code 的取值对应的名字
+var CodeName = map[int32]string{
+	0:  "OK",
+	-1: "ERROR",
+}
+
+// CodeValue Code 的名字对应的取值
+var CodeValue = map[string]int32{
+	"OK":      0,
+	"ERROR":   -1,
+	"TIMEOUT": 0,
+}
+
+func (x Code) Int32() int32 {
+	return int32(x)
+}
+
+func (x Code) String() string {
+	if name, ok := CodeName[int32(x)]; ok {
+		return name
+	}
+	return strconv.Itoa(int(x))
+}
+
+// IsValid 是否是声明过的取值
+func (x Code) IsValid() bool {
+	_, ok := CodeName[int32(x)]
+	return ok
+}
+
+// Header Base::Header
+type Header struct {
+	TraceId       string              `tag:"0" required:"true" json:"traceId"`
+	Code          Code                `tag:"1" json:"code"`
+	UnknownFields gojce.UnknownFields `json:"-"`
+}
+
+func (p *Header) ClassName() string {
+	return "Base.Header"
+}
+
+func (p *Header) MD5() string {
+	return "dc56d7d26968215f040f7d81ac3fba59"
+}
+
+func (p *Header) ResetDefault() {
+	*p = Header{}
+	p.Code = Code_OK
+}
+
+// ResetDefautlt 同 ResetDefault，实现 gojce.Message 接口
+func (p *Header) ResetDefautlt() {
+	p.ResetDefault()
+}
+
+func (p *Header) Encode(w io.Writer) error {
+	var err error
+	encoder := gojce.NewEncoder(w)
+	unknown := p.UnknownFields
+	unknown, err = encoder.WriteUnknownBefore(unknown, 0)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteString(p.TraceId, 0)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 1)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(int32(p.Code), 1)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteUnknown(unknown)
+	if nil != err {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (p *Header) Decode(r io.Reader) error {
+	var err error
+	decoder := gojce.NewDecoder(r)
+	p.ResetDefault()
+	decoder.CaptureUnknown(&p.UnknownFields)
+	var has bool
+	err = decoder.ReadString(&p.TraceId, 0, true)
+	if nil != err {
+		return err
+	}
+	has, err = decoder.HasField(1)
+	if nil != err {
+		return err
+	}
+	if has {
+		err = gojce.ReadEnum(decoder, &p.Code, 1, false)
+		if nil != err {
+			return err
+		}
+	}
+	err = decoder.ReadUnknown()
+	return err
+}
@@ -0,0 +1,325 @@
+// Code generated by jce2go. DO NOT EDIT.
+// source: hello.jce
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gofly/gojce"
+	"github.com/gofly/gojce/cmd/jce2go/testdata/unknown/base"
+)
+
+const (
+	MAX_SIZE int32  = 0x100
+	GREETING string = "hello\tworld"
+)
+
+// HelloReq Test::HelloReq
+type HelloReq struct {
+	Header        base.Header         `tag:"0" required:"true" json:"header"`
+	Name          string              `tag:"1" json:"name"`
+	Ids           []uint32            `tag:"2" json:"ids"`
+	Extra         map[string][]byte   `tag:"3" json:"extra"`
+	Ratio         float64             `tag:"4" json:"ratio"`
+	Flag          bool                `tag:"5" json:"flag"`
+	Stamp         int64               `tag:"16" json:"stamp"`
+	UnknownFields gojce.UnknownFields `json:"-"`
+}
+
+func (p *HelloReq) ClassName() string {
+	return "Test.HelloReq"
+}
+
+func (p *HelloReq) MD5() string {
+	return "84ae20679d29b4f52d246bdfcaabf71b"
+}
+
+func (p *HelloReq) ResetDefault() {
+	*p = HelloReq{}
+	p.Header.ResetDefault()
+	p.Name = "adam"
+	p.Ratio = 1.5
+	p.Flag = true
+	p.Stamp = -1
+}
+
+// ResetDefautlt 同 ResetDefault，实现 gojce.Message 接口
+func (p *HelloReq) ResetDefautlt() {
+	p.ResetDefault()
+}
+
+func (p *HelloReq) Encode(w io.Writer) error {
+	var err error
+	encoder := gojce.NewEncoder(w)
+	unknown := p.UnknownFields
+	unknown, err = encoder.WriteUnknownBefore(unknown, 0)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteStruct(&p.Header, 0)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 1)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteString(p.Name, 1)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 2)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteVector(p.Ids, 2)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 3)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteMap(p.Extra, 3)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 4)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteFloat64(p.Ratio, 4)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 5)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteBool(p.Flag, 5)
+	if nil != err {
+		return err
+	}
+	unknown, err = encoder.WriteUnknownBefore(unknown, 16)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt64(p.Stamp, 16)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteUnknown(unknown)
+	if nil != err {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (p *HelloReq) Decode(r io.Reader) error {
+	var err error
+	decoder := gojce.NewDecoder(r)
+	p.ResetDefault()
+	decoder.CaptureUnknown(&p.UnknownFields)
+	var has bool
+	err = decoder.ReadStruct(&p.Header, 0, true)
+	if nil != err {
+		return err
+	}
+	has, err = decoder.HasField(1)
+	if nil != err {
+		return err
+	}
+	if has {
+		err = decoder.ReadString(&p.Name, 1, false)
+		if nil != err {
+			return err
+		}
+	}
+	err = decoder.ReadVector(&p.Ids, 2, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadMap(&p.Extra, 3, false)
+	if nil != err {
+		return err
+	}
+	has, err = decoder.HasField(4)
+	if nil != err {
+		return err
+	}
+	if has {
+		err = decoder.ReadFloat64(&p.Ratio, 4, false)
+		if nil != err {
+			return err
+		}
+	}
+	has, err = decoder.HasField(5)
+	if nil != err {
+		return err
+	}
+	if has {
+		err = decoder.ReadBool(&p.Flag, 5, false)
+		if nil != err {
+			return err
+		}
+	}
+	has, err = decoder.HasField(16)
+	if nil != err {
+		return err
+	}
+	if has {
+		err = decoder.ReadInt64(&p.Stamp, 16, false)
+		if nil != err {
+			return err
+		}
+	}
+	err = decoder.ReadUnknown()
+	return err
+}
+
+// HelloServant Test::Hello 服务端实现的接口
+type HelloServant interface {
+	SayHello(ctx context.Context, req *HelloReq, rsp *string) (int32, error)
+	Ping(ctx context.Context, uid string) error
+}
+
+// HelloProxy Test::Hello 客户端代理
+type HelloProxy struct {
+	servant string
+	invoker gojce.Invoker
+}
+
+func NewHelloProxy(servant string, invoker gojce.Invoker) *HelloProxy {
+	return &HelloProxy{servant: servant, invoker: invoker}
+}
+
+func (p *HelloProxy) SayHello(ctx context.Context, req *HelloReq, rsp *string) (ret int32, err error) {
+	if nil == req {
+		err = errors.New("sayHello: nil parameter req")
+		return
+	}
+	if nil == rsp {
+		err = errors.New("sayHello: nil parameter rsp")
+		return
+	}
+	buf := new(bytes.Buffer)
+	encoder := gojce.NewEncoder(buf)
+	err = encoder.WriteStruct(req, 1)
+	if nil != err {
+		return
+	}
+	err = encoder.Flush()
+	if nil != err {
+		return
+	}
+	packet := &gojce.RequestPacket{
+		IVersion:     1,
+		SServantName: p.servant,
+		SFuncName:    "sayHello",
+		SBuffer:      buf.Bytes(),
+	}
+	out, err := p.invoker.Invoke(ctx, packet)
+	if nil != err {
+		return
+	}
+	decoder := gojce.NewDecoder(bytes.NewReader(out))
+	err = decoder.ReadInt32(&ret, 0, true)
+	if nil != err {
+		return
+	}
+	err = decoder.ReadString(rsp, 2, true)
+	if nil != err {
+		return
+	}
+	return
+}
+
+func (p *HelloProxy) Ping(ctx context.Context, uid string) (err error) {
+	buf := new(bytes.Buffer)
+	encoder := gojce.NewEncoder(buf)
+	err = encoder.WriteString(uid, 1)
+	if nil != err {
+		return
+	}
+	err = encoder.Flush()
+	if nil != err {
+		return
+	}
+	packet := &gojce.RequestPacket{
+		IVersion:     1,
+		SServantName: p.servant,
+		SFuncName:    "ping",
+		SBuffer:      buf.Bytes(),
+	}
+	_, err = p.invoker.Invoke(ctx, packet)
+	return
+}
+
+// HelloDispatcher 按 SFuncName 将请求分发给 HelloServant
+type HelloDispatcher struct {
+	impl HelloServant
+}
+
+func NewHelloDispatcher(impl HelloServant) *HelloDispatcher {
+	return &HelloDispatcher{impl: impl}
+}
+
+func (d *HelloDispatcher) Dispatch(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+	switch req.SFuncName {
+	case "sayHello":
+		return d.dispatchSayHello(ctx, req.SBuffer)
+	case "ping":
+		return d.dispatchPing(ctx, req.SBuffer)
+	}
+	return nil, fmt.Errorf("%w: %s.%s", gojce.ErrServantFuncNotFound, req.SServantName, req.SFuncName)
+}
+
+func (d *HelloDispatcher) dispatchSayHello(ctx context.Context, in []byte) ([]byte, error) {
+	var err error
+	var req HelloReq
+	var rsp string
+	decoder := gojce.NewDecoder(bytes.NewReader(in))
+	err = decoder.ReadStruct(&req, 1, true)
+	if nil != err {
+		return nil, err
+	}
+	ret, err := d.impl.SayHello(ctx, &req, &rsp)
+	if nil != err {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	encoder := gojce.NewEncoder(buf)
+	err = encoder.WriteInt32(ret, 0)
+	if nil != err {
+		return nil, err
+	}
+	err = encoder.WriteString(rsp, 2)
+	if nil != err {
+		return nil, err
+	}
+	err = encoder.Flush()
+	if nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *HelloDispatcher) dispatchPing(ctx context.Context, in []byte) ([]byte, error) {
+	var err error
+	var uid string
+	decoder := gojce.NewDecoder(bytes.NewReader(in))
+	err = decoder.ReadString(&uid, 1, true)
+	if nil != err {
+		return nil, err
+	}
+	err = d.impl.Ping(ctx, uid)
+	if nil != err {
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gofly/gojce"
+	"github.com/gofly/gojce/idl"
+)
+
+// packetIDL RequestPacket/ResponsePacket 的定义，用于标注字段名
+const packetIDL = `
+module tars
+{
+    struct RequestPacket
+    {
+        1 require short iVersion;
+        2 require byte cPacketType;
+        3 require int iMessageType;
+        4 require int iRequestId;
+        5 require string sServantName;
+        6 require string sFuncName;
+        7 require vector<byte> sBuffer;
+        8 require int iTimeout;
+        9 require map<string, string> context;
+        10 require map<string, string> status;
+    };
+
+    struct ResponsePacket
+    {
+        1 require short iVersion;
+        2 require byte cPacketType;
+        3 require int iRequestId;
+        4 require int iMessageType;
+        5 require int iRet;
+        6 require vector<byte> sBuffer;
+        7 require map<string, string> status;
+        8 optional string sResultDesc;
+        9 optional map<string, string> context;
+    };
+};
+`
+
+// dumper 以缩进的树形式输出 JCE 数据，每行为 tag、编码类型、字段名和值
+type dumper struct {
+	w   io.Writer
+	set *idl.Set
+	err error
+}
+
+func newDumper(w io.Writer, set *idl.Set) (*dumper, error) {
+	f, err := idl.Parse("packet.jce", []byte(packetIDL))
+	if err != nil {
+		return nil, err
+	}
+	s := &idl.Set{Files: []*idl.File{f}}
+	if set != nil {
+		s.Files = append(s.Files, set.Files...)
+	}
+	return &dumper{w: w, set: s}, nil
+}
+
+func (d *dumper) printf(depth int, format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, strings.Repeat("  ", depth)+format+"\n", args...)
+}
+
+// lookup 查找结构体定义，name 为空或找不到时返回 nil
+func (d *dumper) lookup(name string) (string, *idl.Struct) {
+	if name == "" {
+		return "", nil
+	}
+	m, st := d.set.LookupStruct("", strings.ReplaceAll(name, ".", "::"))
+	if st == nil {
+		return "", nil
+	}
+	return m.Name, st
+}
+
+// dump 输出 data 中的所有字段，structName 不为空时按其定义标注字段名
+func (d *dumper) dump(data []byte, structName string, depth int) error {
+	fields, err := gojce.NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		return err
+	}
+	scope, st := d.lookup(structName)
+	d.fields(fields, scope, st, depth)
+	return d.err
+}
+
+// packet 输出 RequestPacket 或 ResponsePacket，并展开 SBuffer
+func (d *dumper) packet(data []byte, response bool, structName string) error {
+	fields, err := gojce.NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		return err
+	}
+	name, bufTag := "tars::RequestPacket", gojce.JceTag(7)
+	if response {
+		name, bufTag = "tars::ResponsePacket", 6
+	}
+	scope, st := d.lookup(name)
+	version := int64(gojce.JceVersion)
+	for _, f := range fields {
+		if f.Tag == 1 {
+			version = f.Value.Int
+		}
+	}
+	for i := range fields {
+		f := &fields[i]
+		if f.Tag != bufTag || f.Value.Type != gojce.SimpleList {
+			d.field(f, scope, st, 0)
+			continue
+		}
+		d.printf(0, "%d %v sBuffer: [%d]", f.Tag, f.Value.Type, len(f.Value.Bytes))
+		if err := d.buffer(f.Value.Bytes, int16(version), structName); err != nil {
+			// SBuffer 无法解析时按字节输出
+			d.printf(1, "%s (%v)", hex.EncodeToString(f.Value.Bytes), err)
+		}
+	}
+	return d.err
+}
+
+// buffer 输出 SBuffer，TUP 协议时逐个展开参数
+func (d *dumper) buffer(data []byte, version int16, structName string) error {
+	if version != gojce.TupVersion && version != gojce.TupVersionV3 {
+		return d.dump(data, structName, 1)
+	}
+	fields, err := gojce.NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		return err
+	}
+	if len(fields) != 1 || fields[0].Value.Type != gojce.Map {
+		return fmt.Errorf("invalid tup buffer")
+	}
+	for _, e := range fields[0].Value.Map {
+		if e.Key.Type != gojce.String1 && e.Key.Type != gojce.String4 {
+			return fmt.Errorf("invalid tup parameter name type: %v", e.Key.Type)
+		}
+		if version == gojce.TupVersionV3 {
+			if err := d.param(string(e.Key.Bytes), "", &e.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.Value.Type != gojce.Map {
+			return fmt.Errorf("invalid tup parameter type: %v", e.Value.Type)
+		}
+		for _, typed := range e.Value.Map {
+			if typed.Key.Type != gojce.String1 && typed.Key.Type != gojce.String4 {
+				return fmt.Errorf("invalid tup type name type: %v", typed.Key.Type)
+			}
+			if err := d.param(string(e.Key.Bytes), string(typed.Key.Bytes), &typed.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// param 输出 TUP 中的一个参数，参数以 tag 0 编码
+func (d *dumper) param(name, typeName string, v *gojce.Value) error {
+	if v.Type != gojce.SimpleList {
+		return fmt.Errorf("invalid tup parameter %s type: %v", name, v.Type)
+	}
+	if typeName != "" {
+		d.printf(1, "%s <%s>", name, typeName)
+	} else {
+		d.printf(1, "%s", name)
+	}
+	fields, err := gojce.NewBytesDecoder(v.Bytes).DecodeAny()
+	if err != nil {
+		return err
+	}
+	var t *idl.Type
+	if _, st := d.lookup(typeName); st != nil {
+		t = &idl.Type{Kind: idl.Named, Name: strings.ReplaceAll(typeName, ".", "::")}
+	}
+	for i := range fields {
+		d.value(2, fields[i].Tag, "", &fields[i].Value, "", t)
+	}
+	return d.err
+}
+
+func (d *dumper) fields(fields []gojce.Field, scope string, st *idl.Struct, depth int) {
+	for i := range fields {
+		d.field(&fields[i], scope, st, depth)
+	}
+}
+
+func (d *dumper) field(f *gojce.Field, scope string, st *idl.Struct, depth int) {
+	var (
+		name string
+		t    *idl.Type
+	)
+	if st != nil {
+		for _, sf := range st.Fields {
+			if sf.Tag == int(f.Tag) {
+				name, t = sf.Name, sf.Type
+				break
+			}
+		}
+	}
+	d.value(depth, f.Tag, name, &f.Value, scope, t)
+}
+
+// value 输出一个值，t 不为 nil 时用于标注枚举和嵌套结构体的字段名
+func (d *dumper) value(depth int, tag gojce.JceTag, name string, v *gojce.Value, scope string, t *idl.Type) {
+	head := fmt.Sprintf("%d %v", tag, v.Type)
+	if name != "" {
+		head += " " + name
+	}
+	switch v.Type {
+	case gojce.Zero, gojce.Int8, gojce.Int16, gojce.Int32, gojce.Int64:
+		d.printf(depth, "%s: %d%s", head, v.Int, d.annotate(v.Int, scope, t))
+	case gojce.Float32, gojce.Float64:
+		d.printf(depth, "%s: %s", head, strconv.FormatFloat(v.Float, 'g', -1, 64))
+	case gojce.String1, gojce.String4:
+		d.printf(depth, "%s: %q", head, v.Bytes)
+	case gojce.SimpleList:
+		d.printf(depth, "%s: [%d] %s", head, len(v.Bytes), hex.EncodeToString(v.Bytes))
+	case gojce.List:
+		d.printf(depth, "%s: [%d]", head, len(v.List))
+		var elem *idl.Type
+		if t != nil && t.Kind == idl.Vector {
+			elem = t.Elem
+		}
+		for i := range v.List {
+			d.value(depth+1, 0, "["+strconv.Itoa(i)+"]", &v.List[i], scope, elem)
+		}
+	case gojce.Map:
+		d.printf(depth, "%s: {%d}", head, len(v.Map))
+		var key, elem *idl.Type
+		if t != nil && t.Kind == idl.Map {
+			key, elem = t.Key, t.Elem
+		}
+		for i := range v.Map {
+			d.value(depth+1, 0, "key", &v.Map[i].Key, scope, key)
+			d.value(depth+1, 1, "value", &v.Map[i].Value, scope, elem)
+		}
+	case gojce.StructBegin:
+		d.printf(depth, "%s", head)
+		var (
+			m  *idl.Module
+			st *idl.Struct
+		)
+		if t != nil && t.Kind == idl.Named {
+			m, st = d.set.LookupStruct(scope, t.Name)
+		}
+		if st != nil {
+			scope = m.Name
+		}
+		d.fields(v.Fields, scope, st, depth+1)
+	default:
+		d.printf(depth, "%s", head)
+	}
+}
+
+// annotate 整数值的补充说明，bool 输出 true/false，枚举输出成员名
+func (d *dumper) annotate(n int64, scope string, t *idl.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case idl.Bool:
+		return fmt.Sprintf(" (%t)", n != 0)
+	case idl.Named:
+		if _, e := d.set.LookupEnum(scope, t.Name); e != nil {
+			for _, em := range e.Members {
+				if int64(em.Value) == n {
+					return " (" + em.Name + ")"
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gofly/gojce"
+	"github.com/gofly/gojce/idl"
+)
+
+func TestDump(t *testing.T) {
+	set, err := idl.Load("../../idl/testdata/hello.jce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := set.FromJSON("Test", "HelloReq", []byte(`{"header":{"traceId":"t","code":"ERROR"},"ids":[7],"extra":{"k":"AQI="}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	d, err := newDumper(&buf, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.dump(req, "Test::HelloReq", 0); err != nil {
+		t.Fatal(err)
+	}
+	want := `0 StructBegin header
+  0 String1 traceId: "t"
+  1 Int8 code: -1 (ERROR)
+1 String1 name: "adam"
+2 List ids: [1]
+  0 Int8 [0]: 7
+3 Map extra: {1}
+  0 String1 key: "k"
+  1 SimpleList value: [2] 0102
+4 Float64 ratio: 1.5
+5 Int8 flag: 1 (true)
+16 Int8 stamp: -1
+`
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// TUP 请求包，按参数的类型名标注字段
+	param := gojce.AppendHead(nil, 0, gojce.StructBegin)
+	param = gojce.AppendString(param, "t", 0)
+	param = gojce.AppendHead(param, 0, gojce.StructEnd)
+	sbuf := gojce.AppendHead(nil, 0, gojce.Map)
+	sbuf = gojce.AppendInt32(sbuf, 1, 0)
+	sbuf = gojce.AppendString(sbuf, "header", 0)
+	sbuf = gojce.AppendHead(sbuf, 1, gojce.Map)
+	sbuf = gojce.AppendInt32(sbuf, 1, 0)
+	sbuf = gojce.AppendString(sbuf, "Base.Header", 0)
+	sbuf = gojce.AppendBytes(sbuf, param, 1)
+	p := &gojce.RequestPacket{IVersion: gojce.TupVersion, IRequestId: 9, SServantName: "s", SFuncName: "f", SBuffer: sbuf}
+	data, err := gojce.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append([]byte{0, 0, 0, 0}, data...)
+	data[3] = byte(len(data))
+	buf.Reset()
+	if err := run(d, data, true, "request", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# frame 0",
+		"4 Int8 iRequestId: 9",
+		"5 String1 sServantName: \"s\"",
+		"7 SimpleList sBuffer: [",
+		"  header <Base.Header>",
+		"    0 StructBegin\n      0 String1 traceId: \"t\"",
+		"10 Map status: {0}",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("missing %q in:\n%s", line, buf.String())
+		}
+	}
+}
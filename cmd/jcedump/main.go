@@ -0,0 +1,142 @@
+// jcedump 以树形式输出 JCE 数据，用于调试
+//
+// 用法:
+//
+//	jcedump [-in raw|hex|base64] [-frame] [-packet request|response] [-idl file.jce -struct Module::Name] [file]
+//	jcedump -hex 0a0c16... / jcedump -base64 CgwW...
+//
+// 不指定 file 或 file 为 - 时读取标准输入
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gofly/gojce/idl"
+)
+
+type includeDirs []string
+
+func (d *includeDirs) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *includeDirs) Set(s string) error {
+	*d = append(*d, s)
+	return nil
+}
+
+func main() {
+	var (
+		hexStr     = flag.String("hex", "", "hex encoded payload")
+		base64Str  = flag.String("base64", "", "base64 encoded payload")
+		in         = flag.String("in", "raw", "encoding of file or stdin: raw, hex or base64")
+		frame      = flag.Bool("frame", false, "input is length prefixed frames")
+		packet     = flag.String("packet", "", "unwrap envelope: request or response, SBuffer is dumped recursively")
+		idlFile    = flag.String("idl", "", ".jce file used to annotate field names")
+		structName = flag.String("struct", "", "struct of the payload (or of SBuffer with -packet), e.g. Test::HelloReq")
+		includes   includeDirs
+	)
+	flag.Var(&includes, "I", "include search directory, can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jcedump [flags] [file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 || *packet != "" && *packet != "request" && *packet != "response" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := readInput(*hexStr, *base64Str, *in, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var set *idl.Set
+	if *idlFile != "" {
+		if set, err = idl.Load(*idlFile, includes...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	d, err := newDumper(os.Stdout, set)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := run(d, data, *frame, *packet, *structName); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readInput 读取输入并按指定的编码解码
+func readInput(hexStr, base64Str, in, name string) ([]byte, error) {
+	if hexStr != "" {
+		return hex.DecodeString(hexStr)
+	}
+	if base64Str != "" {
+		return base64.StdEncoding.DecodeString(base64Str)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if name == "" || name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch in {
+	case "raw":
+		return data, nil
+	case "hex":
+		return hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	case "base64":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	}
+	return nil, fmt.Errorf("unknown input encoding %q", in)
+}
+
+// run 输出 data，frame 为 true 时逐帧输出
+func run(d *dumper, data []byte, frame bool, packet, structName string) error {
+	if !frame {
+		return dumpOne(d, data, packet, structName)
+	}
+	for i := 0; len(data) > 0; i++ {
+		if len(data) < 4 {
+			return errors.New("truncated frame header")
+		}
+		n := int(binary.BigEndian.Uint32(data))
+		if n < 4 || n > len(data) {
+			return fmt.Errorf("invalid frame length %d", n)
+		}
+		d.printf(0, "# frame %d, %d bytes", i, n)
+		if err := dumpOne(d, data[4:n], packet, structName); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func dumpOne(d *dumper, data []byte, packet, structName string) error {
+	switch packet {
+	case "request":
+		return d.packet(data, false, structName)
+	case "response":
+		return d.packet(data, true, structName)
+	}
+	return d.dump(data, structName, 0)
+}
@@ -1,120 +1,15 @@
 package gojce
 
-// XXX: benchmark encode and decode
-
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
+	"unsafe"
 )
 
-type RequestPacket struct {
-	IVersion     int16             `tag:"1"  required:"true"`
-	CPacketType  byte              `tag:"2"  required:"true"`
-	IMessageType int32             `tag:"3"  required:"true"`
-	IRequestId   int64             `tag:"4"  required:"true"`
-	SServantName string            `tag:"5"  required:"true"`
-	SFuncName    string            `tag:"6"  required:"true"`
-	SBuffer      []byte            `tag:"7"  required:"true"`
-	ITimeout     int32             `tag:"8"  required:"true"`
-	Context      map[string]string `tag:"9"  required:"true"`
-	Status       map[string]string `tag:"10"  required:"true"`
-}
-
-func (p *RequestPacket) Encode(w io.Writer) error {
-	var err error
-	encoder := NewEncoder(w)
-	err = encoder.WriteInt16(p.IVersion, 1)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteByte(p.CPacketType, 2)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteInt32(p.IMessageType, 3)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteInt64(p.IRequestId, 4)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteString(p.SServantName, 5)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteString(p.SFuncName, 6)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteBytes(p.SBuffer, 7)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteInt32(p.ITimeout, 8)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteMap(p.Context, 9)
-	if nil != err {
-		return err
-	}
-	err = encoder.WriteMap(p.Status, 10)
-	if nil != err {
-		return err
-	}
-	return encoder.Flush()
-}
-
-func (p *RequestPacket) Decode(r io.Reader) error {
-	var err error
-	decoder := NewDecoder(r)
-	err = decoder.ReadInt16(&p.IVersion, 1, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadByte(&p.CPacketType, 2, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadInt32(&p.IMessageType, 3, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadInt64(&p.IRequestId, 4, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadString(&p.SServantName, 5, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadString(&p.SFuncName, 6, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadBytes(&p.SBuffer, 7, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadInt32(&p.ITimeout, 8, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadMap(&p.Context, 9, true)
-	if nil != err {
-		return err
-	}
-	err = decoder.ReadMap(&p.Status, 10, true)
-	if nil != err {
-		return err
-	}
-	return err
-}
-
 func TestCodec(t *testing.T) {
 	v1 := &RequestPacket{}
 	v1.IVersion = 256
@@ -1273,3 +1168,466 @@ func TestStructArrayCodec(t *testing.T) {
 		t.Fatal(val3)
 	}
 }
+
+type taggedInner struct {
+	Name  string  `tag:"0" required:"true"`
+	Score float64 `tag:"1"`
+}
+
+type taggedPacket struct {
+	ID      int32                  `tag:"1" required:"true"`
+	Title   string                 `tag:"2" required:"true"`
+	Payload []byte                 `tag:"3"`
+	Items   []taggedInner          `tag:"4"`
+	Attrs   map[string]taggedInner `tag:"5"`
+	Inner   taggedInner            `tag:"6"`
+	Comment string                 `tag:"20"`
+	ignored int
+}
+
+func TestTaggedStructCodec(t *testing.T) {
+	val1 := taggedPacket{
+		ID:      23,
+		Title:   "tagged",
+		Payload: []byte("payload"),
+		Items:   []taggedInner{{Name: "a", Score: 1.5}, {Name: "b"}},
+		Attrs:   map[string]taggedInner{"k": {Name: "v", Score: 3}},
+		Inner:   taggedInner{Name: "inner"},
+		Comment: "tag >= 15",
+	}
+	data, err := MarshalStruct(&val1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(hex.EncodeToString(data))
+
+	var val2 taggedPacket
+	err = UnmarshalStruct(data, &val2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(val1, val2) {
+		t.Fatalf("%+v != %+v", val1, val2)
+	}
+
+	// 嵌套在 Encode/Decode 中
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	err = encoder.Encode(val1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder.Flush()
+
+	var val3 taggedPacket
+	decoder := NewDecoder(&buf)
+	err = decoder.Decode(&val3, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(val1, val3) {
+		t.Fatalf("%+v != %+v", val1, val3)
+	}
+}
+
+func TestTaggedStructRequired(t *testing.T) {
+	data, err := MarshalStruct(&taggedInner{Name: "only"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val taggedPacket
+	err = UnmarshalStruct(data, &val)
+	if err == nil {
+		t.Fatal("expect require field error")
+	}
+
+	var inner taggedInner
+	err = UnmarshalStruct(data, &inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.Name != "only" {
+		t.Fatal(inner)
+	}
+}
+
+func TestTaggedStructReuse(t *testing.T) {
+	type reused struct {
+		A int32            `tag:"0"`
+		B string           `tag:"1"`
+		C []int32          `tag:"2"`
+		M map[string]int32 `tag:"3"`
+		P *int32           `tag:"4"`
+	}
+	// 不存在的可选字段置为零值，不保留上一次解码的值
+	n := int32(1)
+	val := reused{A: 1, B: "stale", C: []int32{9}, M: map[string]int32{"x": 1}, P: &n}
+	if err := UnmarshalStruct(AppendInt32(nil, 5, 0), &val); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(val, reused{A: 5}) {
+		t.Fatalf("%+v", val)
+	}
+}
+
+type taggedNode struct {
+	Value    int32        `tag:"0" required:"true"`
+	Children []taggedNode `tag:"1"`
+}
+
+func TestReadAbsentOptional(t *testing.T) {
+	data := AppendInt32(nil, 7, 2)
+
+	// 可选字段不存在时 ReadXxx 写入零值
+	d := NewBytesDecoder(data)
+	s, b := "stale", true
+	if err := d.ReadString(&s, 0, false); err != nil || s != "" {
+		t.Fatal(s, err)
+	}
+	if err := d.ReadBool(&b, 1, false); err != nil || b {
+		t.Fatal(b, err)
+	}
+
+	// HasField 判断后保留原来的值
+	d = NewBytesDecoder(data)
+	s = "default"
+	if has, err := d.HasField(1); err != nil || has {
+		t.Fatal(has, err)
+	}
+	if s != "default" {
+		t.Fatal(s)
+	}
+	if has, err := d.HasField(2); err != nil || !has {
+		t.Fatal(has, err)
+	}
+	var n int32
+	if err := d.ReadInt32(&n, 2, true); err != nil || n != 7 {
+		t.Fatal(n, err)
+	}
+	if has, err := d.HasField(3); err != nil || has {
+		t.Fatal(has, err)
+	}
+}
+
+func TestRecursiveTaggedStructCodec(t *testing.T) {
+	val1 := taggedNode{
+		Value: 1,
+		Children: []taggedNode{
+			{Value: 2, Children: []taggedNode{{Value: 3, Children: []taggedNode{}}}},
+			{Value: 4, Children: []taggedNode{}},
+		},
+	}
+	data, err := MarshalStruct(&val1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val2 taggedNode
+	err = UnmarshalStruct(data, &val2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(val1, val2) {
+		t.Fatalf("%+v != %+v", val1, val2)
+	}
+}
+
+var errBrokenWriter = errors.New("broken writer")
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, errBrokenWriter
+}
+
+type brokenStruct struct{}
+
+func (brokenStruct) Encode(w io.Writer) error { return errBrokenWriter }
+func (brokenStruct) Decode(r io.Reader) error { return nil }
+
+func TestEncoderStickyError(t *testing.T) {
+	e := NewEncoder(brokenWriter{})
+	// 超过 bufio 的缓冲区大小才会真正写入底层 Writer
+	err := e.WriteString(string(bytes.Repeat([]byte("#"), 8192)), 0)
+	if !errors.Is(err, errBrokenWriter) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := e.WriteInt32(1, 1); !errors.Is(err, errBrokenWriter) {
+		t.Fatalf("error not sticky: %v", err)
+	}
+	if err := e.Flush(); !errors.Is(err, errBrokenWriter) {
+		t.Fatalf("flush returned %v", err)
+	}
+
+	var buf bytes.Buffer
+	e = NewEncoder(&buf)
+	if err := e.Encode(make(chan int), 0); err == nil {
+		t.Fatal("unsupported type encoded")
+	}
+	if err := e.WriteInt32(1, 1); err == nil {
+		t.Fatal("error not sticky")
+	}
+	if err := e.Flush(); err == nil || buf.Len() != 0 {
+		t.Fatalf("flush returned %v, wrote %d bytes", err, buf.Len())
+	}
+
+	e = NewEncoder(&buf)
+	if err := e.WriteStruct(brokenStruct{}, 0); !errors.Is(err, errBrokenWriter) {
+		t.Fatalf("struct encode error dropped: %v", err)
+	}
+	if err := e.Flush(); !errors.Is(err, errBrokenWriter) {
+		t.Fatalf("flush returned %v", err)
+	}
+}
+
+// taggedRequestPacket 字段与 RequestPacket 相同，但没有手写的 Encode/Decode
+type taggedRequestPacket RequestPacket
+
+func newBenchPacket() *RequestPacket {
+	return &RequestPacket{
+		IVersion:     1,
+		IRequestId:   10086,
+		SServantName: "Test.HelloServer.HelloObj",
+		SFuncName:    "sayHello",
+		SBuffer:      bytes.Repeat([]byte("#"), 128),
+		ITimeout:     3000,
+		Context:      map[string]string{"trace": "abcdef"},
+		Status:       map[string]string{},
+	}
+}
+
+func BenchmarkEncodeHandWritten(b *testing.B) {
+	p := newBenchPacket()
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := p.Encode(&buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeReflect(b *testing.B) {
+	p := newBenchPacket()
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		encoder := NewEncoder(&buf)
+		if err := encoder.encodeStructFields(reflect.ValueOf((*taggedRequestPacket)(p)).Elem()); err != nil {
+			b.Fatal(err)
+		}
+		encoder.Flush()
+	}
+}
+
+func BenchmarkDecodeHandWritten(b *testing.B) {
+	var buf bytes.Buffer
+	newBenchPacket().Encode(&buf)
+	data := buf.Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p RequestPacket
+		if err := p.Decode(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeReflect(b *testing.B) {
+	var buf bytes.Buffer
+	newBenchPacket().Encode(&buf)
+	data := buf.Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p taggedRequestPacket
+		decoder := NewDecoder(bytes.NewReader(data))
+		if err := decoder.decodeStructFields(reflect.ValueOf(&p).Elem()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	var buf bytes.Buffer
+	newBenchPacket().Encode(&buf)
+	data := buf.Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p RequestPacket
+		if err := NewBytesDecoderWithOptions(data, DecoderOptions{AliasStrings: true}).DecodeStruct(&p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBytesDecoder(t *testing.T) {
+	val1 := taggedPacket{
+		ID:      1,
+		Title:   "bytes",
+		Payload: []byte("payload"),
+		Items:   []taggedInner{{Name: "a", Score: 1.5}},
+		Attrs:   map[string]taggedInner{"b": {Name: "b"}},
+		Inner:   taggedInner{Name: "inner"},
+		Comment: string(bytes.Repeat([]byte("#"), 300)),
+	}
+	data, err := MarshalStruct(&val1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val2 taggedPacket
+	if err := NewBytesDecoder(data).decodeStructFields(reflect.ValueOf(&val2).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(val1, val2) {
+		t.Fatalf("%+v != %+v", val1, val2)
+	}
+
+	// 嵌套的 Struct.Decode 共享读取位置，SBuffer 引用输入数据
+	p := newBenchPacket()
+	data, err = Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p2 RequestPacket
+	if err := NewBytesDecoder(data).DecodeStruct(&p2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, &p2) {
+		t.Fatalf("%+v != %+v", p, p2)
+	}
+	i := bytes.Index(data, p.SBuffer)
+	data[i] = '!'
+	if p2.SBuffer[0] != '!' {
+		t.Fatal("SBuffer copied")
+	}
+	if cap(p2.SBuffer) != len(p2.SBuffer) {
+		t.Fatal("SBuffer capacity exceeds field")
+	}
+	var p3 RequestPacket
+	if err := NewBytesDecoderWithOptions(data, DecoderOptions{AliasStrings: true}).DecodeStruct(&p3); err != nil {
+		t.Fatal(err)
+	}
+	i = bytes.Index(data, []byte(p.SFuncName))
+	if unsafe.StringData(p3.SFuncName) != &data[i] {
+		t.Fatal("SFuncName not aliased")
+	}
+
+	var st struct {
+		Outer RequestPacket `tag:"0"`
+		Next  int32         `tag:"1"`
+	}
+	st.Outer = *p
+	st.Next = 7
+	data, err = MarshalStruct(&st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st2 = st
+	st2.Outer, st2.Next = RequestPacket{}, 0
+	if err := NewBytesDecoder(data).decodeStructFields(reflect.ValueOf(&st2).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if st2.Next != 7 || st2.Outer.SFuncName != p.SFuncName {
+		t.Fatalf("%+v", st2)
+	}
+
+	// 截断的输入，最后 2 个字节是可选字段 Next
+	for n := 1; n < len(data)-2; n++ {
+		if err := NewBytesDecoder(data[:n]).decodeStructFields(reflect.ValueOf(&st2).Elem()); err == nil {
+			t.Fatalf("truncated at %d: expect error", n)
+		}
+	}
+}
+
+func TestAppendMatchesEncoder(t *testing.T) {
+	cases := []struct {
+		write  func(e *Encoder) error
+		append func(dst []byte) []byte
+	}{
+		{func(e *Encoder) error { return e.WriteBool(true, 1) }, func(b []byte) []byte { return AppendBool(b, true, 1) }},
+		{func(e *Encoder) error { return e.WriteInt8(-3, 2) }, func(b []byte) []byte { return AppendInt8(b, -3, 2) }},
+		{func(e *Encoder) error { return e.WriteUint8(200, 3) }, func(b []byte) []byte { return AppendUint8(b, 200, 3) }},
+		{func(e *Encoder) error { return e.WriteInt16(-1000, 4) }, func(b []byte) []byte { return AppendInt16(b, -1000, 4) }},
+		{func(e *Encoder) error { return e.WriteUint16(65535, 5) }, func(b []byte) []byte { return AppendUint16(b, 65535, 5) }},
+		{func(e *Encoder) error { return e.WriteInt32(1<<20, 15) }, func(b []byte) []byte { return AppendInt32(b, 1<<20, 15) }},
+		{func(e *Encoder) error { return e.WriteUint32(1<<31, 16) }, func(b []byte) []byte { return AppendUint32(b, 1<<31, 16) }},
+		{func(e *Encoder) error { return e.WriteInt64(-1<<40, 200) }, func(b []byte) []byte { return AppendInt64(b, -1<<40, 200) }},
+		{func(e *Encoder) error { return e.WriteFloat32(1.5, 0) }, func(b []byte) []byte { return AppendFloat32(b, 1.5, 0) }},
+		{func(e *Encoder) error { return e.WriteFloat64(-2.25, 0) }, func(b []byte) []byte { return AppendFloat64(b, -2.25, 0) }},
+		{func(e *Encoder) error { return e.WriteString("short", 1) }, func(b []byte) []byte { return AppendString(b, "short", 1) }},
+		{func(e *Encoder) error { return e.WriteString(string(make([]byte, 300)), 1) }, func(b []byte) []byte { return AppendString(b, string(make([]byte, 300)), 1) }},
+		{func(e *Encoder) error { return e.WriteBytes([]byte("abc"), 2) }, func(b []byte) []byte { return AppendBytes(b, []byte("abc"), 2) }},
+		{func(e *Encoder) error { return e.WriteStrings([]string{"a", "b"}, 3) }, func(b []byte) []byte { return AppendStrings(b, []string{"a", "b"}, 3) }},
+		{func(e *Encoder) error { return e.WriteMap(map[string]string{"k": "v"}, 4) }, func(b []byte) []byte { return AppendStringMap(b, map[string]string{"k": "v"}, 4) }},
+	}
+	for i, c := range cases {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		if err := c.write(e); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		be := NewBufferEncoder([]byte{0xff})
+		if err := c.write(be); err != nil {
+			t.Fatal(err)
+		}
+		appended := c.append([]byte{0xff})
+		if !bytes.Equal(buf.Bytes(), appended[1:]) || !bytes.Equal(be.Bytes(), appended) {
+			t.Errorf("case %d: %x, %x, %x", i, buf.Bytes(), be.Bytes(), appended)
+		}
+	}
+}
+
+func TestBufferEncoderNoAlloc(t *testing.T) {
+	p := newBenchPacket()
+	want, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewBufferEncoder(make([]byte, 0, 1024))
+	allocs := testing.AllocsPerRun(100, func() {
+		e.Reset(e.Bytes()[:0])
+		if err := e.EncodeStruct(p); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("%v allocs per encode", allocs)
+	}
+	if !bytes.Equal(e.Bytes(), want) {
+		t.Fatalf("%x != %x", e.Bytes(), want)
+	}
+
+	// 嵌套结构体写入同一个缓冲
+	var st struct {
+		Outer RequestPacket `tag:"0"`
+		Next  int32         `tag:"1"`
+	}
+	st.Outer, st.Next = *p, 7
+	e.Reset(nil)
+	if err := e.Encode(&st, 0); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	se := NewEncoder(&buf)
+	se.Encode(&st, 0)
+	se.Flush()
+	if !bytes.Equal(e.Bytes(), buf.Bytes()) {
+		t.Fatalf("%x != %x", e.Bytes(), buf.Bytes())
+	}
+}
+
+func BenchmarkEncodeBuffer(b *testing.B) {
+	p := newBenchPacket()
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = MarshalAppend(buf[:0], p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package gojce
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type defaultInner struct {
+	Code  int32   `tag:"0" default:"-1"`
+	Ratio float32 `tag:"1" default:"0.5"`
+}
+
+type defaultPacket struct {
+	ID      int32         `tag:"0" required:"true"`
+	Name    string        `tag:"1" default:"adam"`
+	Flag    bool          `tag:"2" default:"true"`
+	Mask    uint16        `tag:"3" default:"0xff"`
+	Level   testColor     `tag:"4" default:"2"`
+	Inner   defaultInner  `tag:"5"`
+	Request RequestPacket `tag:"6"`
+	Items   []int32       `tag:"7"`
+}
+
+func TestResetDefault(t *testing.T) {
+	want := defaultPacket{
+		Name:  "adam",
+		Flag:  true,
+		Mask:  0xff,
+		Level: testColorBlue,
+		Inner: defaultInner{Code: -1, Ratio: 0.5},
+	}
+	got := defaultPacket{ID: 3, Name: "x", Items: []int32{1}, Request: RequestPacket{SFuncName: "f"}}
+	if err := ResetDefault(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%+v != %+v", got, want)
+	}
+
+	// 可选字段不存在时使用默认值，存在时使用解码的值
+	data, err := MarshalStruct(&struct {
+		ID   int32  `tag:"0"`
+		Name string `tag:"1"`
+	}{7, "eve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := defaultPacket{Inner: defaultInner{Code: 3}}
+	if err := UnmarshalStruct(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want.ID, want.Name = 7, "eve"
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("%+v != %+v", decoded, want)
+	}
+
+	// 拼写正确和错误的方法都会被调用
+	var req RequestPacket
+	req.SFuncName = "f"
+	if err := ResetDefault(&req); err != nil || req.SFuncName != "" {
+		t.Fatal(err, req)
+	}
+	var m Message = &req
+	m.ResetDefautlt()
+
+	var bad struct {
+		Items []int32 `tag:"0" default:"1"`
+	}
+	if err := ResetDefault(&bad); err == nil || !strings.Contains(err.Error(), "invalid default") {
+		t.Fatal(err)
+	}
+	if err := ResetDefault(bad); err == nil {
+		t.Fatal("expect error for non-pointer")
+	}
+}
@@ -0,0 +1,101 @@
+package gojce
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type testColor int32
+
+const (
+	testColorRed  testColor = 1
+	testColorBlue testColor = 2
+)
+
+func (x testColor) Int32() int32 { return int32(x) }
+
+func (x testColor) String() string {
+	switch x {
+	case testColorRed:
+		return "RED"
+	case testColorBlue:
+		return "BLUE"
+	}
+	return strconv.Itoa(int(x))
+}
+
+func (x testColor) IsValid() bool { return x == testColorRed || x == testColorBlue }
+
+// testLevel 没有实现 ValidEnum，不做检查
+type testLevel int8
+
+func (x testLevel) Int32() int32   { return int32(x) }
+func (x testLevel) String() string { return strconv.Itoa(int(x)) }
+
+type enumPacket struct {
+	Color  testColor            `tag:"0"`
+	Colors []testColor          `tag:"1"`
+	Names  map[testColor]string `tag:"2"`
+	Level  testLevel            `tag:"3"`
+}
+
+func TestEnumCodec(t *testing.T) {
+	val := enumPacket{
+		Color:  testColorBlue,
+		Colors: []testColor{testColorRed, 9},
+		Names:  map[testColor]string{testColorRed: "red"},
+		Level:  -3,
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与整数的编码相同
+	plain, err := MarshalStruct(&struct {
+		Color  int32            `tag:"0"`
+		Colors []int32          `tag:"1"`
+		Names  map[int32]string `tag:"2"`
+		Level  int8             `tag:"3"`
+	}{2, []int32{1, 9}, map[int32]string{1: "red"}, -3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, plain) {
+		t.Fatalf("%x != %x", data, plain)
+	}
+
+	var got enumPacket
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, val) {
+		t.Fatalf("%+v != %+v", got, val)
+	}
+
+	// 严格模式拒绝未声明的取值
+	d := NewBytesDecoderWithOptions(data, DecoderOptions{StrictEnums: true})
+	err = d.decodeStructFields(reflect.ValueOf(&got).Elem())
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrJceDecodeUnknownEnum) || de.Path != "1[1]" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// ReadEnum
+	data = AppendInt32(nil, 7, 2)
+	var c testColor
+	if err := ReadEnum(NewBytesDecoder(data), &c, 2, true); err != nil || c != 7 {
+		t.Fatal(err, c)
+	}
+	c = testColorRed
+	err = ReadEnum(NewBytesDecoderWithOptions(data, DecoderOptions{StrictEnums: true}), &c, 2, true)
+	if !errors.As(err, &de) || !errors.Is(err, ErrJceDecodeUnknownEnum) || de.Path != "2" || c != testColorRed {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 可选字段不存在时与 ReadInt32 相同写入零值，不检查取值
+	err = ReadEnum(NewBytesDecoderWithOptions(data, DecoderOptions{StrictEnums: true}), &c, 3, false)
+	if err != nil || c != 0 {
+		t.Fatal(err, c)
+	}
+}
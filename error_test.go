@@ -0,0 +1,85 @@
+package gojce
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	val := taggedPacket{
+		ID:    1,
+		Title: "error",
+		Items: []taggedInner{{Name: "a"}, {Name: "b"}},
+		Attrs: map[string]taggedInner{"AAA": {Name: "x", Score: 1}},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := Value{Type: String1, Bytes: []byte("s")}
+
+	// map 中结构体的字段类型不匹配
+	attrs := &fields[4].Value
+	if fields[4].Tag != 5 || attrs.Map[0].Value.Fields[1].Tag != 1 {
+		t.Fatalf("unexpected fields %+v", fields)
+	}
+	attrs.Map[0].Value.Fields[1].Value = str
+	bad, err := AppendAny(nil, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalStruct(bad, new(taggedPacket))
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrJceDecodeTypeMismatch) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if de.Path != "taggedPacket.5[key=AAA].1" || de.Expected != Float64 || de.Actual != String1 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if bad[de.Offset] != 0x16 {
+		t.Fatalf("offset %d points to %x", de.Offset, bad[de.Offset])
+	}
+
+	// vector 中结构体缺少必填字段
+	fields[4].Value.Map[0].Value.Fields[1].Value = Value{Type: Float64, Float: 1}
+	items := &fields[3].Value
+	items.List[1].Fields = items.List[1].Fields[1:]
+	if bad, err = AppendAny(nil, []Field{{Tag: 0, Value: Value{Type: StructBegin, Fields: fields}}}); err != nil {
+		t.Fatal(err)
+	}
+	err = NewBytesDecoder(bad).Decode(new(taggedPacket), 0, true)
+	if !errors.As(err, &de) || !errors.Is(err, ErrJceDecodeRequireNotExist) || de.Path != "0.4[1].0" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 生成代码风格的 Message
+	p := newBenchPacket()
+	data, err = Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err = NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields[8].Tag != 9 {
+		t.Fatalf("unexpected fields %+v", fields)
+	}
+	fields[8].Value.Map[0].Value = Value{Type: Int32, Int: 1 << 20}
+	if bad, err = AppendAny(nil, fields); err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(bad, new(RequestPacket))
+	key := string(fields[8].Value.Map[0].Key.Bytes)
+	if !errors.As(err, &de) || de.Path != "RequestPacket.9[key="+key+"]" || de.Actual != Int32 {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = Unmarshal(data[:len(data)/2], new(RequestPacket))
+	if !errors.As(err, &de) || de.Offset <= 0 || de.Path == "" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package gojce
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frameHeaderSize 帧头为 4 字节大端长度，长度包含帧头本身
+const frameHeaderSize = 4
+
+// DefaultMaxFrameSize 默认的最大帧长度
+const DefaultMaxFrameSize = 10 << 20
+
+var ErrFrameTooLarge = errors.New("frame too large")
+var ErrInvalidFrame = errors.New("invalid frame length")
+
+// FrameReader 从流中读取带长度前缀的帧
+type FrameReader struct {
+	r            io.Reader
+	maxFrameSize int
+	header       [frameHeaderSize]byte
+}
+
+// NewFrameReader maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+func NewFrameReader(r io.Reader, maxFrameSize int) *FrameReader {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameReader{r: r, maxFrameSize: maxFrameSize}
+}
+
+// ReadFrame 读取一帧，返回不含帧头的包体
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.header[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(fr.header[:]))
+	if size < frameHeaderSize {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidFrame, size)
+	}
+	if size > fr.maxFrameSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, size, fr.maxFrameSize)
+	}
+	body := make([]byte, size-frameHeaderSize)
+	if _, err := io.ReadFull(fr.r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// ReadRequest 读取一帧并解码为 RequestPacket，SBuffer 直接引用帧数据
+func (fr *FrameReader) ReadRequest(p *RequestPacket) error {
+	body, err := fr.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return NewBytesDecoder(body).DecodeStruct(p)
+}
+
+// ReadResponse 读取一帧并解码为 ResponsePacket，SBuffer 直接引用帧数据
+func (fr *FrameReader) ReadResponse(p *ResponsePacket) error {
+	body, err := fr.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return NewBytesDecoder(body).DecodeStruct(p)
+}
+
+// FrameWriter 向流中写入带长度前缀的帧，每帧只调用一次 Write
+type FrameWriter struct {
+	w            io.Writer
+	maxFrameSize int
+	buf          []byte
+}
+
+// NewFrameWriter maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+func NewFrameWriter(w io.Writer, maxFrameSize int) *FrameWriter {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameWriter{w: w, maxFrameSize: maxFrameSize}
+}
+
+// WriteFrame 写入一帧，body 不含帧头
+func (fw *FrameWriter) WriteFrame(body []byte) error {
+	fw.buf = append(fw.buf[:0], make([]byte, frameHeaderSize)...)
+	fw.buf = append(fw.buf, body...)
+	return fw.flush()
+}
+
+// WriteMessage 编码 m 并写入一帧，编码缓冲在多次调用间复用
+func (fw *FrameWriter) WriteMessage(m Struct) error {
+	buf, err := MarshalAppend(append(fw.buf[:0], make([]byte, frameHeaderSize)...), m)
+	if err != nil {
+		return err
+	}
+	fw.buf = buf
+	return fw.flush()
+}
+
+func (fw *FrameWriter) flush() error {
+	frame := fw.buf
+	if len(frame) > fw.maxFrameSize {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(frame), fw.maxFrameSize)
+	}
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)))
+	_, err := fw.w.Write(frame)
+	return err
+}
@@ -0,0 +1,228 @@
+// Package idl 解析 JCE/Tars 接口描述文件(.jce)，生成语法树
+package idl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos 源文件中的位置，行列均从 1 开始
+type Pos struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Pos) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// File 一个 .jce 文件
+type File struct {
+	Name     string
+	Includes []*Include
+	Modules  []*Module
+}
+
+// Include `#include "xxx.jce"`
+type Include struct {
+	Pos  Pos
+	Path string
+}
+
+// Module `module Name { ... };`
+type Module struct {
+	Pos        Pos
+	Name       string
+	Structs    []*Struct
+	Enums      []*Enum
+	Consts     []*Const
+	Interfaces []*Interface
+	Keys       []*Key
+}
+
+// Struct `struct Name { ... };`
+type Struct struct {
+	Pos    Pos
+	Name   string
+	Fields []*Field
+}
+
+// Field `tag require|optional type name [= default];`
+type Field struct {
+	Pos      Pos
+	Tag      int
+	Required bool
+	Type     *Type
+	Name     string
+	Default  *Value
+}
+
+// Enum `enum Name { A, B = 2 };`
+type Enum struct {
+	Pos     Pos
+	Name    string
+	Members []*EnumMember
+}
+
+// EnumMember 枚举成员，Value 为计算后的取值
+type EnumMember struct {
+	Pos   Pos
+	Name  string
+	Value int32
+	// Explicit 源文件中是否显式赋值
+	Explicit *Value
+}
+
+// Const `const type name = value;`
+type Const struct {
+	Pos   Pos
+	Type  *Type
+	Name  string
+	Value *Value
+}
+
+// Interface `interface Name { ... };`
+type Interface struct {
+	Pos        Pos
+	Name       string
+	Operations []*Operation
+}
+
+// Operation 接口方法，Return 为 void 时 Kind 为 Void
+type Operation struct {
+	Pos    Pos
+	Name   string
+	Return *Type
+	Params []*Param
+}
+
+// Param 方法参数
+type Param struct {
+	Pos      Pos
+	Name     string
+	Type     *Type
+	Out      bool
+	RouteKey bool
+}
+
+// Key `key[Struct, field1, field2];`
+type Key struct {
+	Pos    Pos
+	Struct string
+	Fields []string
+}
+
+// TypeKind 类型种类
+type TypeKind int
+
+const (
+	Void TypeKind = iota
+	Bool
+	Byte
+	Short
+	Int
+	Long
+	Float
+	Double
+	String
+	Vector
+	Map
+	Named
+)
+
+var typeKindNames = [...]string{
+	Void:   "void",
+	Bool:   "bool",
+	Byte:   "byte",
+	Short:  "short",
+	Int:    "int",
+	Long:   "long",
+	Float:  "float",
+	Double: "double",
+	String: "string",
+	Vector: "vector",
+	Map:    "map",
+	Named:  "named",
+}
+
+func (k TypeKind) String() string {
+	if int(k) < len(typeKindNames) {
+		return typeKindNames[k]
+	}
+	return "unknown"
+}
+
+// Type 类型，Named 表示结构体或枚举，Name 可能带 `Module::` 前缀
+type Type struct {
+	Pos      Pos
+	Kind     TypeKind
+	Unsigned bool
+	Key      *Type
+	Elem     *Type
+	Name     string
+}
+
+// String 返回 IDL 中的书写形式
+func (t *Type) String() string {
+	switch t.Kind {
+	case Vector:
+		return "vector<" + t.Elem.String() + ">"
+	case Map:
+		return "map<" + t.Key.String() + ", " + t.Elem.String() + ">"
+	case Named:
+		return t.Name
+	}
+	if t.Unsigned {
+		return "unsigned " + t.Kind.String()
+	}
+	return t.Kind.String()
+}
+
+// ValueKind 常量值种类
+type ValueKind int
+
+const (
+	IntValue ValueKind = iota
+	FloatValue
+	StringValue
+	BoolValue
+	// IdentValue 枚举成员或常量名
+	IdentValue
+)
+
+// Value 常量值，Text 保留源文件中的字面量
+type Value struct {
+	Pos   Pos
+	Kind  ValueKind
+	Text  string
+	Int   int64
+	Float float64
+	Str   string
+	Bool  bool
+}
+
+func (v *Value) String() string {
+	return v.Text
+}
+
+// Error 语法错误
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// QualifiedName 拼接 `Module::Name`
+func QualifiedName(module, name string) string {
+	if strings.Contains(name, "::") {
+		return name
+	}
+	return module + "::" + name
+}
@@ -0,0 +1,539 @@
+package idl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofly/gojce"
+)
+
+// ToJSON 按 scope 模块中的结构体 name 将 JCE 数据转换为 JSON 对象，key 为字段名。
+// bool 转为 true/false，枚举转为成员名，vector<byte> 转为 base64；
+// 缺失的可选字段取默认值或零值，IDL 中没有的 tag 以 tag 作为 key 输出
+func (s *Set) ToJSON(scope, name string, data []byte) ([]byte, error) {
+	m, st := s.LookupStruct(scope, name)
+	if st == nil {
+		return nil, fmt.Errorf("undefined struct %s", name)
+	}
+	fields, err := gojce.NewBytesDecoder(data).DecodeAny()
+	if err != nil {
+		return nil, err
+	}
+	return s.appendStructJSON(nil, m.Name, st, fields, st.Name)
+}
+
+// FromJSON 按 scope 模块中的结构体 name 将 JSON 对象编码为 JCE，结果与生成代码的 Encode 相同。
+// 缺失的字段取默认值或零值，枚举可以是成员名或数字，不认识的字段返回错误
+func (s *Set) FromJSON(scope, name string, js []byte) ([]byte, error) {
+	m, st := s.LookupStruct(scope, name)
+	if st == nil {
+		return nil, fmt.Errorf("undefined struct %s", name)
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expect json object", st.Name)
+	}
+	return s.appendStructJCE(nil, m.Name, st, obj, st.Name)
+}
+
+func (s *Set) appendStructJSON(dst []byte, scope string, st *Struct, fields []gojce.Field, path string) ([]byte, error) {
+	known := make(map[gojce.JceTag]bool, len(st.Fields))
+	dst = append(dst, '{')
+	var err error
+	for i, f := range st.Fields {
+		tag := gojce.JceTag(f.Tag)
+		known[tag] = true
+		fpath := path + "." + f.Name
+		v := findField(fields, tag)
+		if v == nil {
+			if f.Required {
+				return dst, fmt.Errorf("%s: require field not exist, tag: %d", fpath, f.Tag)
+			}
+			if v, err = s.zeroValue(scope, f, fpath); err != nil {
+				return dst, err
+			}
+		}
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONKey(dst, f.Name)
+		if dst, err = s.appendJSON(dst, scope, f.Type, v, fpath); err != nil {
+			return dst, err
+		}
+	}
+	comma := len(st.Fields) > 0
+	for i := range fields {
+		if known[fields[i].Tag] {
+			continue
+		}
+		if comma {
+			dst = append(dst, ',')
+		}
+		comma = true
+		dst = appendJSONKey(dst, strconv.Itoa(int(fields[i].Tag)))
+		if dst, err = gojce.AppendValueJSON(dst, &fields[i].Value); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+func findField(fields []gojce.Field, tag gojce.JceTag) *gojce.Value {
+	for i := range fields {
+		if fields[i].Tag == tag {
+			return &fields[i].Value
+		}
+	}
+	return nil
+}
+
+// zeroValue 缺失字段的值，通过编码默认值再解码得到
+func (s *Set) zeroValue(scope string, f *Field, path string) (*gojce.Value, error) {
+	b, err := s.appendJCE(nil, scope, f.Type, 0, s.defaultJSON(scope, f.Type, f.Default), path)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := gojce.NewBytesDecoder(b).DecodeAny()
+	if err != nil {
+		return nil, err
+	}
+	return &fields[0].Value, nil
+}
+
+func appendJSONKey(dst []byte, key string) []byte {
+	b, _ := json.Marshal(key)
+	dst = append(dst, b...)
+	return append(dst, ':')
+}
+
+func isInt(v *gojce.Value) bool {
+	switch v.Type {
+	case gojce.Zero, gojce.Int8, gojce.Int16, gojce.Int32, gojce.Int64:
+		return true
+	}
+	return false
+}
+
+func mismatch(path string, t *Type, v *gojce.Value) error {
+	return fmt.Errorf("%s: type %s mismatch, get type: %v", path, t, v.Type)
+}
+
+func (s *Set) appendJSON(dst []byte, scope string, t *Type, v *gojce.Value, path string) ([]byte, error) {
+	var err error
+	switch t.Kind {
+	case Bool:
+		if !isInt(v) {
+			return dst, mismatch(path, t, v)
+		}
+		return strconv.AppendBool(dst, v.Int != 0), nil
+	case Byte, Short, Int, Long:
+		if !isInt(v) {
+			return dst, mismatch(path, t, v)
+		}
+		return strconv.AppendInt(dst, v.Int, 10), nil
+	case Float, Double:
+		if isInt(v) {
+			return strconv.AppendInt(dst, v.Int, 10), nil
+		}
+		if v.Type != gojce.Float32 && v.Type != gojce.Float64 {
+			return dst, mismatch(path, t, v)
+		}
+		return gojce.AppendValueJSON(dst, v)
+	case String:
+		if v.Type != gojce.String1 && v.Type != gojce.String4 {
+			return dst, mismatch(path, t, v)
+		}
+		return gojce.AppendValueJSON(dst, v)
+	case Vector:
+		if t.Elem.Kind == Byte && v.Type == gojce.SimpleList {
+			return gojce.AppendValueJSON(dst, v)
+		}
+		if v.Type != gojce.List {
+			return dst, mismatch(path, t, v)
+		}
+		if t.Elem.Kind == Byte {
+			// 按 List 编码的 vector<byte>
+			b := make([]byte, len(v.List))
+			for i := range v.List {
+				if !isInt(&v.List[i]) {
+					return dst, mismatch(path, t.Elem, &v.List[i])
+				}
+				b[i] = byte(v.List[i].Int)
+			}
+			return gojce.AppendValueJSON(dst, &gojce.Value{Type: gojce.SimpleList, Bytes: b})
+		}
+		dst = append(dst, '[')
+		for i := range v.List {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = s.appendJSON(dst, scope, t.Elem, &v.List[i], path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, ']'), nil
+	case Map:
+		if v.Type != gojce.Map {
+			return dst, mismatch(path, t, v)
+		}
+		if !s.scalarKey(scope, t.Key) {
+			dst = append(dst, '[')
+			for i := range v.Map {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				epath := path + "[" + strconv.Itoa(i) + "]"
+				dst = append(dst, `{"key":`...)
+				if dst, err = s.appendJSON(dst, scope, t.Key, &v.Map[i].Key, epath); err != nil {
+					return dst, err
+				}
+				dst = append(dst, `,"value":`...)
+				if dst, err = s.appendJSON(dst, scope, t.Elem, &v.Map[i].Value, epath); err != nil {
+					return dst, err
+				}
+				dst = append(dst, '}')
+			}
+			return append(dst, ']'), nil
+		}
+		dst = append(dst, '{')
+		var key []byte
+		for i := range v.Map {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if key, err = s.appendJSON(key[:0], scope, t.Key, &v.Map[i].Key, path); err != nil {
+				return dst, err
+			}
+			// 整数 key 需要加上引号
+			if len(key) == 0 || key[0] != '"' {
+				dst = append(dst, '"')
+				dst = append(dst, key...)
+				dst = append(dst, '"')
+			} else {
+				dst = append(dst, key...)
+			}
+			dst = append(dst, ':')
+			if dst, err = s.appendJSON(dst, scope, t.Elem, &v.Map[i].Value, path+"["+string(key)+"]"); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, '}'), nil
+	case Named:
+		if m, st := s.LookupStruct(scope, t.Name); st != nil {
+			if v.Type != gojce.StructBegin {
+				return dst, mismatch(path, t, v)
+			}
+			return s.appendStructJSON(dst, m.Name, st, v.Fields, path)
+		}
+		if _, e := s.LookupEnum(scope, t.Name); e != nil {
+			if !isInt(v) {
+				return dst, mismatch(path, t, v)
+			}
+			for _, em := range e.Members {
+				if int64(em.Value) == v.Int {
+					return strconv.AppendQuote(dst, em.Name), nil
+				}
+			}
+			// 未定义的枚举值按数字输出
+			return strconv.AppendInt(dst, v.Int, 10), nil
+		}
+		return dst, fmt.Errorf("%s: undefined type %s", path, t.Name)
+	}
+	return dst, fmt.Errorf("%s: unsupported type %s", path, t)
+}
+
+// scalarKey map 的 key 能否作为 JSON 对象的 key
+func (s *Set) scalarKey(scope string, t *Type) bool {
+	switch t.Kind {
+	case Byte, Short, Int, Long, String:
+		return true
+	case Named:
+		_, e := s.LookupEnum(scope, t.Name)
+		return e != nil
+	}
+	return false
+}
+
+// defaultJSON 字段的默认值，没有默认值时为类型的零值
+func (s *Set) defaultJSON(scope string, t *Type, def *Value) any {
+	if def != nil {
+		switch def.Kind {
+		case IntValue:
+			return json.Number(strconv.FormatInt(def.Int, 10))
+		case FloatValue:
+			return json.Number(strconv.FormatFloat(def.Float, 'g', -1, 64))
+		case BoolValue:
+			return def.Bool
+		default:
+			return def.Str
+		}
+	}
+	switch t.Kind {
+	case Bool:
+		return false
+	case String:
+		return ""
+	case Vector:
+		if t.Elem.Kind == Byte {
+			return ""
+		}
+		return []any{}
+	case Map:
+		return map[string]any{}
+	case Named:
+		if _, st := s.LookupStruct(scope, t.Name); st != nil {
+			return map[string]any{}
+		}
+	}
+	return json.Number("0")
+}
+
+func (s *Set) appendStructJCE(dst []byte, scope string, st *Struct, obj map[string]any, path string) ([]byte, error) {
+	fields := make([]*Field, len(st.Fields))
+	copy(fields, st.Fields)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Tag < fields[j].Tag
+	})
+	names := make(map[string]bool, len(fields))
+	var err error
+	for _, f := range fields {
+		names[f.Name] = true
+		v, ok := obj[f.Name]
+		if !ok || v == nil {
+			v = s.defaultJSON(scope, f.Type, f.Default)
+		}
+		if dst, err = s.appendJCE(dst, scope, f.Type, gojce.JceTag(f.Tag), v, path+"."+f.Name); err != nil {
+			return dst, err
+		}
+	}
+	for k := range obj {
+		if !names[k] {
+			return dst, fmt.Errorf("%s: unknown field %q", path, k)
+		}
+	}
+	return dst, nil
+}
+
+func expect(path string, t *Type, v any) error {
+	return fmt.Errorf("%s: cannot convert %T to %s", path, v, t)
+}
+
+// intRange 整数类型的取值范围
+func intRange(t *Type) (int64, int64) {
+	switch t.Kind {
+	case Byte:
+		if t.Unsigned {
+			return 0, math.MaxUint8
+		}
+		return math.MinInt8, math.MaxInt8
+	case Short:
+		if t.Unsigned {
+			return 0, math.MaxUint16
+		}
+		return math.MinInt16, math.MaxInt16
+	case Int:
+		if t.Unsigned {
+			return 0, math.MaxUint32
+		}
+		return math.MinInt32, math.MaxInt32
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+func parseInt(path string, t *Type, v any) (int64, error) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, expect(path, t, v)
+	}
+	n, err := strconv.ParseInt(string(num), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid %s value %s", path, t, num)
+	}
+	if min, max := intRange(t); n < min || n > max {
+		return 0, fmt.Errorf("%s: value %d out of range for %s", path, n, t)
+	}
+	return n, nil
+}
+
+func parseFloat(path string, t *Type, v any) (float64, error) {
+	switch f := v.(type) {
+	case json.Number:
+		return f.Float64()
+	case string:
+		switch f {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+	}
+	return 0, expect(path, t, v)
+}
+
+// mapKey 将 JSON 对象的 key 转为 key 类型对应的值
+func mapKey(t *Type, k string) any {
+	if t.Kind == String {
+		return k
+	}
+	if _, err := strconv.ParseInt(k, 10, 64); err == nil {
+		return json.Number(k)
+	}
+	// 枚举成员名
+	return k
+}
+
+func (s *Set) appendJCE(dst []byte, scope string, t *Type, tag gojce.JceTag, v any, path string) ([]byte, error) {
+	var err error
+	switch t.Kind {
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return dst, expect(path, t, v)
+		}
+		return gojce.AppendBool(dst, b, tag), nil
+	case Byte, Short, Int, Long:
+		n, err := parseInt(path, t, v)
+		if err != nil {
+			return dst, err
+		}
+		return gojce.AppendInt64(dst, n, tag), nil
+	case Float:
+		f, err := parseFloat(path, t, v)
+		if err != nil {
+			return dst, err
+		}
+		return gojce.AppendFloat32(dst, float32(f), tag), nil
+	case Double:
+		f, err := parseFloat(path, t, v)
+		if err != nil {
+			return dst, err
+		}
+		return gojce.AppendFloat64(dst, f, tag), nil
+	case String:
+		str, ok := v.(string)
+		if !ok {
+			return dst, expect(path, t, v)
+		}
+		return gojce.AppendString(dst, str, tag), nil
+	case Vector:
+		if t.Elem.Kind == Byte {
+			str, ok := v.(string)
+			if !ok {
+				return dst, expect(path, t, v)
+			}
+			b, err := base64.StdEncoding.DecodeString(str)
+			if err != nil {
+				return dst, fmt.Errorf("%s: %v", path, err)
+			}
+			return gojce.AppendBytes(dst, b, tag), nil
+		}
+		list, ok := v.([]any)
+		if !ok {
+			return dst, expect(path, t, v)
+		}
+		dst = gojce.AppendHead(dst, tag, gojce.List)
+		dst = gojce.AppendInt32(dst, int32(len(list)), 0)
+		for i, elem := range list {
+			if dst, err = s.appendJCE(dst, scope, t.Elem, 0, elem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return dst, err
+			}
+		}
+		return dst, nil
+	case Map:
+		switch m := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			dst = gojce.AppendHead(dst, tag, gojce.Map)
+			dst = gojce.AppendInt32(dst, int32(len(keys)), 0)
+			for _, k := range keys {
+				epath := path + "[" + k + "]"
+				if dst, err = s.appendJCE(dst, scope, t.Key, 0, mapKey(t.Key, k), epath); err != nil {
+					return dst, err
+				}
+				if dst, err = s.appendJCE(dst, scope, t.Elem, 1, m[k], epath); err != nil {
+					return dst, err
+				}
+			}
+			return dst, nil
+		case []any:
+			// 不能作为 JSON 对象 key 的类型使用 [{"key":..., "value":...}]
+			dst = gojce.AppendHead(dst, tag, gojce.Map)
+			dst = gojce.AppendInt32(dst, int32(len(m)), 0)
+			for i, elem := range m {
+				epath := path + "[" + strconv.Itoa(i) + "]"
+				entry, ok := elem.(map[string]any)
+				if !ok {
+					return dst, fmt.Errorf("%s: expect {\"key\":..., \"value\":...}", epath)
+				}
+				if dst, err = s.appendJCE(dst, scope, t.Key, 0, entry["key"], epath); err != nil {
+					return dst, err
+				}
+				if dst, err = s.appendJCE(dst, scope, t.Elem, 1, entry["value"], epath); err != nil {
+					return dst, err
+				}
+			}
+			return dst, nil
+		}
+		return dst, expect(path, t, v)
+	case Named:
+		if m, st := s.LookupStruct(scope, t.Name); st != nil {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return dst, expect(path, t, v)
+			}
+			dst = gojce.AppendHead(dst, tag, gojce.StructBegin)
+			if dst, err = s.appendStructJCE(dst, m.Name, st, obj, path); err != nil {
+				return dst, err
+			}
+			return gojce.AppendHead(dst, 0, gojce.StructEnd), nil
+		}
+		if _, e := s.LookupEnum(scope, t.Name); e != nil {
+			n, err := s.enumValue(e, v, path)
+			if err != nil {
+				return dst, err
+			}
+			return gojce.AppendInt32(dst, n, tag), nil
+		}
+		return dst, fmt.Errorf("%s: undefined type %s", path, t.Name)
+	}
+	return dst, fmt.Errorf("%s: unsupported type %s", path, t)
+}
+
+// enumValue 枚举值可以是成员名（允许带 `Enum::` 前缀）或数字
+func (s *Set) enumValue(e *Enum, v any, path string) (int32, error) {
+	switch ev := v.(type) {
+	case json.Number:
+		n, err := parseInt(path, &Type{Kind: Int}, ev)
+		return int32(n), err
+	case string:
+		name := ev
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			name = name[i+2:]
+		}
+		for _, em := range e.Members {
+			if em.Name == name {
+				return em.Value, nil
+			}
+		}
+		return 0, fmt.Errorf("%s: undefined enum member %s of %s", path, ev, e.Name)
+	}
+	return 0, fmt.Errorf("%s: cannot convert %T to enum %s", path, v, e.Name)
+}
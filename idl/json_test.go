@@ -0,0 +1,52 @@
+package idl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofly/gojce"
+)
+
+func TestJSON(t *testing.T) {
+	set, err := Load("testdata/hello.jce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := set.FromJSON("Test", "Base::Header", []byte(`{"traceId":"t","code":-1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IDL 中没有的 tag 按 tag 输出
+	data = gojce.AppendString(data, "extra", 7)
+	js, err := set.ToJSON("Base", "Header", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"traceId":"t","code":"ERROR","7":"extra"}`; string(js) != want {
+		t.Fatalf("%s != %s", js, want)
+	}
+
+	if _, err := set.ToJSON("Base", "Header", gojce.AppendInt32(nil, 1, 1)); err == nil || !strings.Contains(err.Error(), "Header.traceId") {
+		t.Fatal(err)
+	}
+	if _, err := set.ToJSON("Base", "Header", gojce.AppendString(nil, "t", 1)); err == nil {
+		t.Fatal("expect type mismatch")
+	}
+	for _, c := range []struct {
+		js, msg string
+	}{
+		{`{"header":{"traceId":"t"},"nick":"x"}`, `unknown field "nick"`},
+		{`{"header":{"traceId":"t"},"ids":[-1]}`, "HelloReq.ids[0]: value -1 out of range"},
+		{`{"header":{"traceId":"t","code":"UNKNOWN"}}`, "undefined enum member UNKNOWN"},
+		{`{"header":{"traceId":"t"},"extra":{"k":"!"}}`, "HelloReq.extra[k]"},
+		{`{"header":{"traceId":1}}`, "HelloReq.header.traceId: cannot convert json.Number to string"},
+		{`[]`, "expect json object"},
+	} {
+		if _, err := set.FromJSON("Test", "HelloReq", []byte(c.js)); err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: %v", c.js, err)
+		}
+	}
+	if _, err := set.FromJSON("Test", "Missing", []byte(`{}`)); err == nil {
+		t.Fatal("expect undefined struct")
+	}
+}
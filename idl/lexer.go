@@ -0,0 +1,265 @@
+package idl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokInclude
+	tokPunct
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of file"
+	case tokIdent:
+		return "identifier"
+	case tokInt:
+		return "integer"
+	case tokFloat:
+		return "float"
+	case tokString:
+		return "string"
+	case tokInclude:
+		return "#include"
+	}
+	return "punctuation"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// lexer 词法分析器，支持 // 和 /* */ 注释
+type lexer struct {
+	filename string
+	src      []byte
+	off      int
+	line     int
+	col      int
+}
+
+func newLexer(filename string, src []byte) *lexer {
+	return &lexer{filename: filename, src: src, line: 1, col: 1}
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Filename: l.filename, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peekByte(n int) byte {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n]
+	}
+	return 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+func (l *lexer) skipSpaceAndComments() error {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance()
+		case c == '/' && l.peekByte(1) == '/':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		case c == '/' && l.peekByte(1) == '*':
+			start := l.pos()
+			l.advance()
+			l.advance()
+			for {
+				if l.off >= len(l.src) {
+					return l.errorf(start, "unterminated comment")
+				}
+				if l.src[l.off] == '*' && l.peekByte(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	pos := l.pos()
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+	c := l.src[l.off]
+	switch {
+	case isLetter(c):
+		start := l.off
+		for l.off < len(l.src) && (isLetter(l.src[l.off]) || isDigit(l.src[l.off])) {
+			l.advance()
+		}
+		return token{kind: tokIdent, text: string(l.src[start:l.off]), pos: pos}, nil
+	case isDigit(c) || (c == '-' || c == '+' || c == '.') && (isDigit(l.peekByte(1)) || l.peekByte(1) == '.'):
+		return l.number(pos)
+	case c == '"':
+		return l.str(pos)
+	case c == '#':
+		start := l.off
+		l.advance()
+		for l.off < len(l.src) && isLetter(l.src[l.off]) {
+			l.advance()
+		}
+		word := string(l.src[start:l.off])
+		if word != "#include" {
+			return token{}, l.errorf(pos, "unknown directive %q", word)
+		}
+		return token{kind: tokInclude, text: word, pos: pos}, nil
+	case c == ':' && l.peekByte(1) == ':':
+		l.advance()
+		l.advance()
+		return token{kind: tokPunct, text: "::", pos: pos}, nil
+	case strings.IndexByte("{}[]()<>;,=", c) >= 0:
+		l.advance()
+		return token{kind: tokPunct, text: string(c), pos: pos}, nil
+	}
+	return token{}, l.errorf(pos, "unexpected character %q", c)
+}
+
+func (l *lexer) number(pos Pos) (token, error) {
+	start := l.off
+	if c := l.src[l.off]; c == '-' || c == '+' {
+		l.advance()
+	}
+	if l.peekByte(0) == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') {
+		l.advance()
+		l.advance()
+		if !isHexDigit(l.peekByte(0)) {
+			return token{}, l.errorf(pos, "malformed hex literal")
+		}
+		for l.off < len(l.src) && isHexDigit(l.src[l.off]) {
+			l.advance()
+		}
+		return token{kind: tokInt, text: string(l.src[start:l.off]), pos: pos}, nil
+	}
+	kind := tokInt
+	for l.off < len(l.src) && isDigit(l.src[l.off]) {
+		l.advance()
+	}
+	if l.peekByte(0) == '.' {
+		kind = tokFloat
+		l.advance()
+		for l.off < len(l.src) && isDigit(l.src[l.off]) {
+			l.advance()
+		}
+	}
+	if c := l.peekByte(0); c == 'e' || c == 'E' {
+		kind = tokFloat
+		l.advance()
+		if c := l.peekByte(0); c == '-' || c == '+' {
+			l.advance()
+		}
+		if !isDigit(l.peekByte(0)) {
+			return token{}, l.errorf(pos, "malformed float literal")
+		}
+		for l.off < len(l.src) && isDigit(l.src[l.off]) {
+			l.advance()
+		}
+	}
+	// 兼容 1.0f 这种写法
+	if c := l.peekByte(0); kind == tokFloat && (c == 'f' || c == 'F') {
+		l.advance()
+	}
+	if l.off < len(l.src) && isLetter(l.src[l.off]) {
+		return token{}, l.errorf(pos, "malformed number literal")
+	}
+	return token{kind: kind, text: string(l.src[start:l.off]), pos: pos}, nil
+}
+
+func (l *lexer) str(pos Pos) (token, error) {
+	l.advance()
+	var sb strings.Builder
+	for {
+		if l.off >= len(l.src) || l.src[l.off] == '\n' {
+			return token{}, l.errorf(pos, "unterminated string literal")
+		}
+		c := l.advance()
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			if l.off >= len(l.src) {
+				return token{}, l.errorf(pos, "unterminated string literal")
+			}
+			esc := l.advance()
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '0':
+				sb.WriteByte(0)
+			case '\\', '"', '\'':
+				sb.WriteByte(esc)
+			default:
+				return token{}, l.errorf(pos, "unknown escape sequence \\%c", esc)
+			}
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return token{kind: tokString, text: sb.String(), pos: pos}, nil
+}
@@ -0,0 +1,186 @@
+package idl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Set 入口文件及其通过 #include 引用的全部文件，被依赖的文件排在前面
+type Set struct {
+	Files []*File
+}
+
+// Load 解析入口文件并递归处理 #include，先在包含者所在目录查找，再依次查找 includeDirs
+func Load(filename string, includeDirs ...string) (*Set, error) {
+	l := &loader{
+		includeDirs: includeDirs,
+		state:       make(map[string]int),
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.load(filename, abs, nil); err != nil {
+		return nil, err
+	}
+	s := &Set{Files: l.files}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+const (
+	loading = iota + 1
+	loaded
+)
+
+type loader struct {
+	includeDirs []string
+	state       map[string]int
+	files       []*File
+}
+
+func (l *loader) load(name, abs string, from *Include) error {
+	switch l.state[abs] {
+	case loading:
+		return &Error{Pos: from.Pos, Msg: fmt.Sprintf("include cycle detected at %q", from.Path)}
+	case loaded:
+		return nil
+	}
+	l.state[abs] = loading
+	f, err := ParseFile(name)
+	if err != nil {
+		return err
+	}
+	for _, inc := range f.Includes {
+		path, err := l.resolve(filepath.Dir(name), inc)
+		if err != nil {
+			return err
+		}
+		incAbs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if err := l.load(path, incAbs, inc); err != nil {
+			return err
+		}
+	}
+	l.state[abs] = loaded
+	l.files = append(l.files, f)
+	return nil
+}
+
+func (l *loader) resolve(dir string, inc *Include) (string, error) {
+	if filepath.IsAbs(inc.Path) {
+		return inc.Path, nil
+	}
+	candidates := append([]string{dir}, l.includeDirs...)
+	for _, d := range candidates {
+		path := filepath.Join(d, inc.Path)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", &Error{Pos: inc.Pos, Msg: fmt.Sprintf("include file %q not found", inc.Path)}
+}
+
+// splitName 拆分 `Module::Name`，不带模块名时使用 scope
+func splitName(scope, name string) (string, string) {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[:i], name[i+2:]
+	}
+	return scope, name
+}
+
+// LookupStruct 在 scope 模块中查找结构体，name 可以带 `Module::` 前缀
+func (s *Set) LookupStruct(scope, name string) (*Module, *Struct) {
+	module, name := splitName(scope, name)
+	for _, f := range s.Files {
+		for _, m := range f.Modules {
+			if m.Name != module {
+				continue
+			}
+			for _, st := range m.Structs {
+				if st.Name == name {
+					return m, st
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// LookupEnum 在 scope 模块中查找枚举，name 可以带 `Module::` 前缀
+func (s *Set) LookupEnum(scope, name string) (*Module, *Enum) {
+	module, name := splitName(scope, name)
+	for _, f := range s.Files {
+		for _, m := range f.Modules {
+			if m.Name != module {
+				continue
+			}
+			for _, e := range m.Enums {
+				if e.Name == name {
+					return m, e
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Check 检查所有自定义类型都能被解析
+func (s *Set) Check() error {
+	for _, f := range s.Files {
+		for _, m := range f.Modules {
+			for _, st := range m.Structs {
+				for _, field := range st.Fields {
+					if err := s.checkType(m.Name, field.Type); err != nil {
+						return err
+					}
+				}
+			}
+			for _, c := range m.Consts {
+				if err := s.checkType(m.Name, c.Type); err != nil {
+					return err
+				}
+			}
+			for _, i := range m.Interfaces {
+				for _, op := range i.Operations {
+					if err := s.checkType(m.Name, op.Return); err != nil {
+						return err
+					}
+					for _, p := range op.Params {
+						if err := s.checkType(m.Name, p.Type); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Set) checkType(scope string, t *Type) error {
+	switch t.Kind {
+	case Vector:
+		return s.checkType(scope, t.Elem)
+	case Map:
+		if err := s.checkType(scope, t.Key); err != nil {
+			return err
+		}
+		return s.checkType(scope, t.Elem)
+	case Named:
+		if _, st := s.LookupStruct(scope, t.Name); st != nil {
+			return nil
+		}
+		if _, e := s.LookupEnum(scope, t.Name); e != nil {
+			return nil
+		}
+		return &Error{Pos: t.Pos, Msg: fmt.Sprintf("undefined type %s", t.Name)}
+	}
+	return nil
+}
@@ -0,0 +1,698 @@
+package idl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parser 递归下降语法分析器，遇到第一个错误即停止
+type parser struct {
+	lex  *lexer
+	tok  token
+	file *File
+}
+
+// Parse 解析 src 中的 .jce 内容，filename 仅用于错误信息中的位置
+func Parse(filename string, src []byte) (*File, error) {
+	p := &parser{
+		lex:  newLexer(filename, src),
+		file: &File{Name: filename},
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+// ParseFile 读取并解析单个 .jce 文件，不处理 #include
+func ParseFile(filename string) (*File, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filename, src)
+}
+
+func (p *parser) next() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected(want string) error {
+	return p.errorf(p.tok.pos, "expected %s, found %s", want, p.tok)
+}
+
+func (p *parser) isPunct(s string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == s
+}
+
+func (p *parser) isKeyword(s string) bool {
+	return p.tok.kind == tokIdent && p.tok.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.unexpected("'" + s + "'")
+	}
+	return p.next()
+}
+
+func (p *parser) expectKeyword(s string) error {
+	if !p.isKeyword(s) {
+		return p.unexpected("'" + s + "'")
+	}
+	return p.next()
+}
+
+var keywords = map[string]bool{
+	"module": true, "struct": true, "enum": true, "const": true, "interface": true,
+	"key": true, "require": true, "optional": true, "out": true, "routekey": true,
+	"void": true, "bool": true, "byte": true, "short": true, "int": true, "long": true,
+	"float": true, "double": true, "string": true, "vector": true, "map": true,
+	"unsigned": true, "true": true, "false": true,
+}
+
+func (p *parser) ident(what string) (string, Pos, error) {
+	if p.tok.kind != tokIdent || keywords[p.tok.text] {
+		return "", p.tok.pos, p.unexpected(what)
+	}
+	name, pos := p.tok.text, p.tok.pos
+	return name, pos, p.next()
+}
+
+// closeBlock 解析 `}` 以及可选的 `;`
+func (p *parser) closeBlock() error {
+	if err := p.expectPunct("}"); err != nil {
+		return err
+	}
+	if p.isPunct(";") {
+		return p.next()
+	}
+	return nil
+}
+
+func (p *parser) parseFile() error {
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.kind == tokInclude:
+			pos := p.tok.pos
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.tok.kind != tokString {
+				return p.unexpected("include path string")
+			}
+			p.file.Includes = append(p.file.Includes, &Include{Pos: pos, Path: p.tok.text})
+			if err := p.next(); err != nil {
+				return err
+			}
+		case p.isKeyword("module"):
+			m, err := p.parseModule()
+			if err != nil {
+				return err
+			}
+			p.file.Modules = append(p.file.Modules, m)
+		default:
+			return p.unexpected("'module' or '#include'")
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseModule() (*Module, error) {
+	m := &Module{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, _, err := p.ident("module name")
+	if err != nil {
+		return nil, err
+	}
+	m.Name = name
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	names := make(map[string]Pos)
+	declare := func(name string, pos Pos) error {
+		if prev, ok := names[name]; ok {
+			return p.errorf(pos, "%s redeclared in module %s, previous declaration at %s", name, m.Name, prev)
+		}
+		names[name] = pos
+		return nil
+	}
+	for !p.isPunct("}") {
+		switch {
+		case p.isKeyword("struct"):
+			s, err := p.parseStruct()
+			if err != nil {
+				return nil, err
+			}
+			if err := declare(s.Name, s.Pos); err != nil {
+				return nil, err
+			}
+			m.Structs = append(m.Structs, s)
+		case p.isKeyword("enum"):
+			e, err := p.parseEnum()
+			if err != nil {
+				return nil, err
+			}
+			if err := declare(e.Name, e.Pos); err != nil {
+				return nil, err
+			}
+			m.Enums = append(m.Enums, e)
+		case p.isKeyword("const"):
+			c, err := p.parseConst()
+			if err != nil {
+				return nil, err
+			}
+			if err := declare(c.Name, c.Pos); err != nil {
+				return nil, err
+			}
+			m.Consts = append(m.Consts, c)
+		case p.isKeyword("interface"):
+			i, err := p.parseInterface()
+			if err != nil {
+				return nil, err
+			}
+			if err := declare(i.Name, i.Pos); err != nil {
+				return nil, err
+			}
+			m.Interfaces = append(m.Interfaces, i)
+		case p.isKeyword("key"):
+			k, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			m.Keys = append(m.Keys, k)
+		default:
+			return nil, p.unexpected("'struct', 'enum', 'const', 'interface', 'key' or '}'")
+		}
+	}
+	if err := p.closeBlock(); err != nil {
+		return nil, err
+	}
+	for _, k := range m.Keys {
+		if err := checkKey(m, k); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (p *parser) parseStruct() (*Struct, error) {
+	s := &Struct{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, _, err := p.ident("struct name")
+	if err != nil {
+		return nil, err
+	}
+	s.Name = name
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	tags := make(map[int]bool)
+	names := make(map[string]bool)
+	for !p.isPunct("}") {
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		if tags[f.Tag] {
+			return nil, p.errorf(f.Pos, "duplicate tag %d in struct %s", f.Tag, s.Name)
+		}
+		if names[f.Name] {
+			return nil, p.errorf(f.Pos, "duplicate field %s in struct %s", f.Name, s.Name)
+		}
+		tags[f.Tag] = true
+		names[f.Name] = true
+		s.Fields = append(s.Fields, f)
+	}
+	return s, p.closeBlock()
+}
+
+func (p *parser) parseField() (*Field, error) {
+	f := &Field{Pos: p.tok.pos}
+	if p.tok.kind != tokInt {
+		return nil, p.unexpected("field tag")
+	}
+	tag, err := strconv.ParseInt(p.tok.text, 0, 32)
+	if err != nil || tag < 0 || tag > 255 {
+		return nil, p.errorf(p.tok.pos, "invalid field tag %s, must be in [0, 255]", p.tok.text)
+	}
+	f.Tag = int(tag)
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("require"):
+		f.Required = true
+	case p.isKeyword("optional"):
+	default:
+		return nil, p.unexpected("'require' or 'optional'")
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if f.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if f.Type.Kind == Void {
+		return nil, p.errorf(f.Type.Pos, "field cannot be void")
+	}
+	if f.Name, _, err = p.ident("field name"); err != nil {
+		return nil, err
+	}
+	if p.isPunct("=") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if f.Default, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+		if err := checkValue(f.Type, f.Default); err != nil {
+			return nil, err
+		}
+	}
+	return f, p.expectPunct(";")
+}
+
+func (p *parser) parseEnum() (*Enum, error) {
+	e := &Enum{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, _, err := p.ident("enum name")
+	if err != nil {
+		return nil, err
+	}
+	e.Name = name
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	values := make(map[string]int32)
+	next := int32(0)
+	for !p.isPunct("}") {
+		name, pos, err := p.ident("enum member name")
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := values[name]; ok {
+			return nil, p.errorf(pos, "duplicate enum member %s in enum %s", name, e.Name)
+		}
+		m := &EnumMember{Pos: pos, Name: name, Value: next}
+		if p.isPunct("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			switch v.Kind {
+			case IntValue:
+				if v.Int < -1<<31 || v.Int > 1<<31-1 {
+					return nil, p.errorf(v.Pos, "enum value %s overflows int32", v.Text)
+				}
+				m.Value = int32(v.Int)
+			case IdentValue:
+				ref, ok := values[v.Str]
+				if !ok {
+					return nil, p.errorf(v.Pos, "undefined enum member %s", v.Str)
+				}
+				m.Value = ref
+			default:
+				return nil, p.errorf(v.Pos, "enum value must be an integer, found %s", v.Text)
+			}
+			m.Explicit = v
+		}
+		values[name] = m.Value
+		next = m.Value + 1
+		e.Members = append(e.Members, m)
+		if !p.isPunct(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return e, p.closeBlock()
+}
+
+func (p *parser) parseConst() (*Const, error) {
+	c := &Const{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	switch c.Type.Kind {
+	case Void, Vector, Map, Named:
+		return nil, p.errorf(c.Type.Pos, "invalid const type %s", c.Type)
+	}
+	if c.Name, _, err = p.ident("const name"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("="); err != nil {
+		return nil, err
+	}
+	if c.Value, err = p.parseValue(); err != nil {
+		return nil, err
+	}
+	if err := checkValue(c.Type, c.Value); err != nil {
+		return nil, err
+	}
+	return c, p.expectPunct(";")
+}
+
+func (p *parser) parseInterface() (*Interface, error) {
+	i := &Interface{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, _, err := p.ident("interface name")
+	if err != nil {
+		return nil, err
+	}
+	i.Name = name
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for !p.isPunct("}") {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		if names[op.Name] {
+			return nil, p.errorf(op.Pos, "duplicate operation %s in interface %s", op.Name, i.Name)
+		}
+		names[op.Name] = true
+		i.Operations = append(i.Operations, op)
+	}
+	return i, p.closeBlock()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Pos: p.tok.pos}
+	var err error
+	if op.Return, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if op.Name, _, err = p.ident("operation name"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for !p.isPunct(")") {
+		param := &Param{Pos: p.tok.pos}
+		for {
+			if p.isKeyword("out") {
+				param.Out = true
+			} else if p.isKeyword("routekey") {
+				param.RouteKey = true
+			} else {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if param.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if param.Type.Kind == Void {
+			return nil, p.errorf(param.Type.Pos, "parameter cannot be void")
+		}
+		if param.Name, _, err = p.ident("parameter name"); err != nil {
+			return nil, err
+		}
+		if names[param.Name] {
+			return nil, p.errorf(param.Pos, "duplicate parameter %s in operation %s", param.Name, op.Name)
+		}
+		names[param.Name] = true
+		op.Params = append(op.Params, param)
+		if !p.isPunct(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return op, p.expectPunct(";")
+}
+
+func (p *parser) parseKey() (*Key, error) {
+	k := &Key{Pos: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+	name, _, err := p.ident("struct name")
+	if err != nil {
+		return nil, err
+	}
+	k.Struct = name
+	for p.isPunct(",") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		field, _, err := p.ident("field name")
+		if err != nil {
+			return nil, err
+		}
+		k.Fields = append(k.Fields, field)
+	}
+	if err := p.expectPunct("]"); err != nil {
+		return nil, err
+	}
+	return k, p.expectPunct(";")
+}
+
+func (p *parser) parseType() (*Type, error) {
+	t := &Type{Pos: p.tok.pos}
+	if p.tok.kind != tokIdent {
+		return nil, p.unexpected("type")
+	}
+	word := p.tok.text
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch word {
+	case "void":
+		t.Kind = Void
+	case "bool":
+		t.Kind = Bool
+	case "byte":
+		t.Kind = Byte
+	case "short":
+		t.Kind = Short
+	case "int":
+		t.Kind = Int
+	case "long":
+		t.Kind = Long
+	case "float":
+		t.Kind = Float
+	case "double":
+		t.Kind = Double
+	case "string":
+		t.Kind = String
+	case "unsigned":
+		t.Unsigned = true
+		switch {
+		case p.isKeyword("byte"):
+			t.Kind = Byte
+		case p.isKeyword("short"):
+			t.Kind = Short
+		case p.isKeyword("int"):
+			t.Kind = Int
+		default:
+			return nil, p.unexpected("'byte', 'short' or 'int' after 'unsigned'")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	case "vector":
+		t.Kind = Vector
+		if err := p.expectPunct("<"); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.Elem = elem
+		if err := p.expectPunct(">"); err != nil {
+			return nil, err
+		}
+	case "map":
+		t.Kind = Map
+		if err := p.expectPunct("<"); err != nil {
+			return nil, err
+		}
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.Key, t.Elem = key, elem
+		if err := p.expectPunct(">"); err != nil {
+			return nil, err
+		}
+	default:
+		if keywords[word] {
+			return nil, p.errorf(t.Pos, "expected type, found '%s'", word)
+		}
+		t.Kind = Named
+		parts := []string{word}
+		for p.isPunct("::") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, _, err := p.ident("type name")
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, name)
+		}
+		t.Name = strings.Join(parts, "::")
+	}
+	if (t.Kind == Vector || t.Kind == Map) && t.Elem.Kind == Void || t.Kind == Map && t.Key.Kind == Void {
+		return nil, p.errorf(t.Pos, "invalid container type %s", t)
+	}
+	return t, nil
+}
+
+func (p *parser) parseValue() (*Value, error) {
+	v := &Value{Pos: p.tok.pos, Text: p.tok.text}
+	switch p.tok.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(p.tok.text, 0, 64)
+		if err != nil {
+			// 兼容 unsigned 的 64 位十六进制常量
+			u, uerr := strconv.ParseUint(p.tok.text, 0, 64)
+			if uerr != nil {
+				return nil, p.errorf(v.Pos, "invalid integer literal %s", p.tok.text)
+			}
+			n = int64(u)
+		}
+		v.Kind, v.Int = IntValue, n
+	case tokFloat:
+		f, err := strconv.ParseFloat(strings.TrimRight(p.tok.text, "fF"), 64)
+		if err != nil {
+			return nil, p.errorf(v.Pos, "invalid float literal %s", p.tok.text)
+		}
+		v.Kind, v.Float = FloatValue, f
+	case tokString:
+		v.Kind, v.Str = StringValue, p.tok.text
+		v.Text = strconv.Quote(p.tok.text)
+	case tokIdent:
+		switch p.tok.text {
+		case "true", "false":
+			v.Kind, v.Bool = BoolValue, p.tok.text == "true"
+		default:
+			if keywords[p.tok.text] {
+				return nil, p.unexpected("value")
+			}
+			v.Kind = IdentValue
+			parts := []string{p.tok.text}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			for p.isPunct("::") {
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+				name, _, err := p.ident("identifier")
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, name)
+			}
+			v.Str = strings.Join(parts, "::")
+			v.Text = v.Str
+			return v, nil
+		}
+	default:
+		return nil, p.unexpected("value")
+	}
+	return v, p.next()
+}
+
+// checkValue 检查常量/默认值与类型是否匹配
+func checkValue(t *Type, v *Value) error {
+	ok := false
+	switch t.Kind {
+	case Bool:
+		ok = v.Kind == BoolValue
+	case Byte, Short, Int, Long:
+		ok = v.Kind == IntValue
+	case Float, Double:
+		ok = v.Kind == IntValue || v.Kind == FloatValue
+	case String:
+		ok = v.Kind == StringValue
+	case Named:
+		// 枚举默认值，名字在生成代码时解析
+		ok = v.Kind == IdentValue || v.Kind == IntValue
+	}
+	if !ok {
+		return &Error{Pos: v.Pos, Msg: fmt.Sprintf("cannot use %s as %s value", v.Text, t)}
+	}
+	if v.Kind == IntValue && t.Kind != Named {
+		if min, max := intRange(t); v.Int < min || v.Int > max {
+			return &Error{Pos: v.Pos, Msg: fmt.Sprintf("%s overflows %s", v.Text, t)}
+		}
+	}
+	return nil
+}
+
+func checkKey(m *Module, k *Key) error {
+	for _, s := range m.Structs {
+		if s.Name != k.Struct {
+			continue
+		}
+		for _, name := range k.Fields {
+			found := false
+			for _, f := range s.Fields {
+				if f.Name == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return &Error{Pos: k.Pos, Msg: fmt.Sprintf("key field %s not found in struct %s", name, k.Struct)}
+			}
+		}
+		return nil
+	}
+	return &Error{Pos: k.Pos, Msg: fmt.Sprintf("key struct %s not found in module %s", k.Struct, m.Name)}
+}
@@ -0,0 +1,102 @@
+package idl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	set, err := Load("testdata/hello.jce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Files) != 2 {
+		t.Fatal(len(set.Files))
+	}
+	if set.Files[0].Name != "testdata/base.jce" {
+		t.Fatal(set.Files[0].Name)
+	}
+
+	_, e := set.LookupEnum("Test", "Base::Code")
+	if e == nil {
+		t.Fatal("enum Base::Code not found")
+	}
+	if e.Members[1].Value != -1 || e.Members[2].Value != 0 {
+		t.Fatal(e.Members[1].Value, e.Members[2].Value)
+	}
+
+	m, s := set.LookupStruct("Test", "HelloReq")
+	if s == nil {
+		t.Fatal("struct HelloReq not found")
+	}
+	if len(s.Fields) != 7 {
+		t.Fatal(len(s.Fields))
+	}
+	if f := s.Fields[0]; !f.Required || f.Type.Kind != Named || f.Type.Name != "Base::Header" {
+		t.Fatal(f)
+	}
+	if f := s.Fields[1]; f.Required || f.Default.Str != "adam" {
+		t.Fatal(f)
+	}
+	if f := s.Fields[2]; f.Type.String() != "vector<unsigned int>" {
+		t.Fatal(f.Type)
+	}
+	if f := s.Fields[3]; f.Type.String() != "map<string, vector<byte>>" {
+		t.Fatal(f.Type)
+	}
+	if f := s.Fields[6]; f.Tag != 16 || f.Default.Int != -1 {
+		t.Fatal(f)
+	}
+	if f := s.Fields[1]; f.Pos.Line != 14 || f.Pos.Column != 9 {
+		t.Fatal(f.Pos)
+	}
+
+	if m.Consts[0].Value.Int != 256 || m.Consts[1].Value.Str != "hello\tworld" {
+		t.Fatal(m.Consts)
+	}
+	if len(m.Keys) != 1 || m.Keys[0].Fields[0] != "name" {
+		t.Fatal(m.Keys)
+	}
+	ops := m.Interfaces[0].Operations
+	if len(ops) != 2 || !ops[0].Params[1].Out || !ops[1].Params[0].RouteKey || ops[1].Return.Kind != Void {
+		t.Fatal(ops)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		err string
+	}{
+		{"module A { struct S { 0 require int a } };", "x.jce:1:39: expected ';', found '}'"},
+		{"module A { struct S { 0 must int a; }; };", "x.jce:1:25: expected 'require' or 'optional', found 'must'"},
+		{"module A {\n struct S {\n  0 require int a;\n  0 optional int b;\n };\n};", "x.jce:4:3: duplicate tag 0 in struct S"},
+		{"module A { struct S { 256 require int a; }; };", "invalid field tag 256"},
+		{"module A { struct S { 0 optional int a = \"x\"; }; };", "cannot use \"x\" as int value"},
+		{"module A { struct S { 0 optional byte b = 300; }; };", "x.jce:1:43: 300 overflows byte"},
+		{"module A { struct S { 0 optional int b = 99999999999; }; };", "99999999999 overflows int"},
+		{"module A { struct S { 0 optional unsigned short b = -1; }; };", "-1 overflows unsigned short"},
+		{"module A { const byte B = -129; };", "-129 overflows byte"},
+		{"module A { enum E { X = Y }; };", "undefined enum member Y"},
+		{"module A { key[S, a]; };", "key struct S not found"},
+		{"module A { struct S { 0 optional vector<int a; }; };", "expected '>', found 'a'"},
+		{"module A { /* open", "unterminated comment"},
+		{"module A { const string s = \"abc; };", "unterminated string literal"},
+	}
+	for _, c := range cases {
+		_, err := Parse("x.jce", []byte(c.src))
+		if err == nil {
+			t.Fatalf("%q: expect error", c.src)
+		}
+		if !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: got %q, want %q", c.src, err, c.err)
+		}
+	}
+}
+
+func TestLoadUndefinedType(t *testing.T) {
+	_, err := Load("testdata/undefined.jce")
+	if err == nil || !strings.Contains(err.Error(), "undefined type Missing") {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+package gojce
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// AppendXxx 将带 tag 的值编码后追加到 dst 并返回新的切片，编码结果与对应的 Encoder.WriteXxx 相同
+
+// AppendHead 追加字段头
+func AppendHead(dst []byte, tag JceTag, tagType JceEncodeType) []byte {
+	if tag < 15 {
+		return append(dst, byte((uint8(tag)<<4)+uint8(tagType)))
+	}
+	return append(dst, byte(tagType+240), byte(tag))
+}
+
+func AppendBool(dst []byte, v bool, tag JceTag) []byte {
+	if v {
+		return AppendInt8(dst, 1, tag)
+	}
+	return AppendInt8(dst, 0, tag)
+}
+
+func AppendInt8(dst []byte, v int8, tag JceTag) []byte {
+	if v == 0 {
+		return AppendHead(dst, tag, Zero)
+	}
+	dst = AppendHead(dst, tag, Int8)
+	return append(dst, byte(v))
+}
+
+func AppendByte(dst []byte, v byte, tag JceTag) []byte {
+	return AppendInt8(dst, int8(v), tag)
+}
+
+func AppendUint8(dst []byte, v uint8, tag JceTag) []byte {
+	return AppendInt64(dst, int64(v), tag)
+}
+
+func AppendInt16(dst []byte, v int16, tag JceTag) []byte {
+	if v >= -128 && v <= 127 {
+		return AppendInt8(dst, int8(v), tag)
+	}
+	dst = AppendHead(dst, tag, Int16)
+	return binary.BigEndian.AppendUint16(dst, uint16(v))
+}
+
+func AppendUint16(dst []byte, v uint16, tag JceTag) []byte {
+	return AppendInt64(dst, int64(v), tag)
+}
+
+func AppendInt32(dst []byte, v int32, tag JceTag) []byte {
+	if v >= -32768 && v <= 32767 {
+		return AppendInt16(dst, int16(v), tag)
+	}
+	dst = AppendHead(dst, tag, Int32)
+	return binary.BigEndian.AppendUint32(dst, uint32(v))
+}
+
+func AppendUint32(dst []byte, v uint32, tag JceTag) []byte {
+	return AppendInt64(dst, int64(v), tag)
+}
+
+func AppendInt64(dst []byte, v int64, tag JceTag) []byte {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return AppendInt32(dst, int32(v), tag)
+	}
+	dst = AppendHead(dst, tag, Int64)
+	return binary.BigEndian.AppendUint64(dst, uint64(v))
+}
+
+func AppendFloat32(dst []byte, v float32, tag JceTag) []byte {
+	dst = AppendHead(dst, tag, Float32)
+	return binary.BigEndian.AppendUint32(dst, math.Float32bits(v))
+}
+
+func AppendFloat64(dst []byte, v float64, tag JceTag) []byte {
+	dst = AppendHead(dst, tag, Float64)
+	return binary.BigEndian.AppendUint64(dst, math.Float64bits(v))
+}
+
+// appendStringHead 追加字符串的字段头和长度
+func appendStringHead(dst []byte, n int, tag JceTag) []byte {
+	if n > 255 {
+		dst = AppendHead(dst, tag, String4)
+		return binary.BigEndian.AppendUint32(dst, uint32(n))
+	}
+	dst = AppendHead(dst, tag, String1)
+	return append(dst, byte(n))
+}
+
+func AppendString(dst []byte, v string, tag JceTag) []byte {
+	dst = appendStringHead(dst, len(v), tag)
+	return append(dst, v...)
+}
+
+// appendBytesHead 追加 vector<byte> 的字段头和长度
+func appendBytesHead(dst []byte, n int, tag JceTag) []byte {
+	dst = AppendHead(dst, tag, SimpleList)
+	dst = AppendHead(dst, 0, Int8)
+	return AppendInt32(dst, int32(n), 0)
+}
+
+// AppendBytes 以 SimpleList 编码 vector<byte>
+func AppendBytes(dst []byte, v []byte, tag JceTag) []byte {
+	dst = appendBytesHead(dst, len(v), tag)
+	return append(dst, v...)
+}
+
+func AppendStrings(dst []byte, v []string, tag JceTag) []byte {
+	dst = AppendHead(dst, tag, List)
+	dst = AppendInt32(dst, int32(len(v)), 0)
+	for _, s := range v {
+		dst = AppendString(dst, s, 0)
+	}
+	return dst
+}
+
+// AppendStringMap 编码 map<string, string>
+func AppendStringMap(dst []byte, v map[string]string, tag JceTag) []byte {
+	dst = AppendHead(dst, tag, Map)
+	dst = AppendInt32(dst, int32(len(v)), 0)
+	for k, s := range v {
+		dst = AppendString(dst, k, 0)
+		dst = AppendString(dst, s, 1)
+	}
+	return dst
+}
+
+var bufferEncoderPool = sync.Pool{
+	New: func() any { return NewBufferEncoder(nil) },
+}
+
+// MarshalAppend 将 m 编码后追加到 dst，dst 容量足够时不分配内存
+func MarshalAppend(dst []byte, m Struct) ([]byte, error) {
+	e := bufferEncoderPool.Get().(*Encoder)
+	e.Reset(dst)
+	err := e.EncodeStruct(m)
+	buf := e.Bytes()
+	e.Reset(nil)
+	bufferEncoderPool.Put(e)
+	if err != nil {
+		return dst, err
+	}
+	return buf, nil
+}
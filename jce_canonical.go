@@ -0,0 +1,96 @@
+package gojce
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// EncoderOptions 编码选项
+type EncoderOptions struct {
+	// Canonical map 按 key 的编码结果排序后写入，同样的值总是得到同样的字节，
+	// 用于计算哈希、签名和比较编码结果；嵌套结构体的 Encoder 继承该选项
+	Canonical bool
+}
+
+// NewEncoderWithOptions 创建带编码选项的 Encoder
+func NewEncoderWithOptions(w io.Writer, opts EncoderOptions) *Encoder {
+	e := NewEncoder(w)
+	e.opts = opts
+	return e
+}
+
+// NewBufferEncoderWithOptions 创建带编码选项的 NewBufferEncoder
+func NewBufferEncoderWithOptions(buf []byte, opts EncoderOptions) *Encoder {
+	e := NewBufferEncoder(buf)
+	e.opts = opts
+	return e
+}
+
+// MarshalCanonical 以 Canonical 选项编码 v，v 可以是 Struct 或带 `tag:"N"` 标注的结构体
+func MarshalCanonical(v any) ([]byte, error) {
+	e := NewBufferEncoderWithOptions(nil, EncoderOptions{Canonical: true})
+	if s, ok := v.(Struct); ok {
+		if err := e.EncodeStruct(s); err != nil {
+			return nil, err
+		}
+		return e.Bytes(), nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	if err := e.encodeStructFields(rv); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// encodeCanonicalMap 先编码所有的 key，按编码结果排序后写入 map
+func (e *Encoder) encodeCanonicalMap(tag JceTag, v reflect.Value, key, elem *typeCodec) error {
+	type entry struct {
+		start, end int
+		value      reflect.Value
+	}
+	ke := NewBufferEncoderWithOptions(nil, e.opts)
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		start := len(ke.buf)
+		if err := key.encode(ke, 0, iter.Key()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{start, len(ke.buf), iter.Value()})
+	}
+	keys := ke.buf
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(keys[entries[i].start:entries[i].end], keys[entries[j].start:entries[j].end]) < 0
+	})
+
+	e.encodeHeaderTag(tag, Map)
+	e.encodeTagInt32Value(0, int32(len(entries)))
+	for _, kv := range entries {
+		e.write(keys[kv.start:kv.end])
+		if err := elem.encode(e, 1, kv.value); err != nil {
+			return err
+		}
+	}
+	return e.err
+}
+
+// sortedStringKeys 按编码结果的顺序返回 m 的 key：
+// 短的 key 的长度字段和类型都不大于长的 key，因此先按长度再按内容排序
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
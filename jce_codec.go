@@ -0,0 +1,595 @@
+package gojce
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+type encodeFunc func(e *Encoder, tag JceTag, v reflect.Value) error
+type decodeFunc func(d *Decoder, tag JceTag, required bool, v reflect.Value) error
+
+// typeCodec 某个类型编译好的编解码计划，按 reflect.Type 缓存
+type typeCodec struct {
+	encode encodeFunc
+	decode decodeFunc
+	// fields 仅对带 tag 标注的普通结构体有效
+	fields []fieldCodec
+	// unknown UnknownFields 字段的下标，没有时为 nil
+	unknown []int
+	err     error
+}
+
+type fieldCodec struct {
+	structField
+	codec *typeCodec
+}
+
+var (
+	codecCache sync.Map // map[reflect.Type]*typeCodec
+	codecMu    sync.Mutex
+)
+
+// codecFor 返回类型 t 的编解码计划，首次调用时编译并缓存
+func codecFor(t reflect.Type) *typeCodec {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*typeCodec)
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*typeCodec)
+	}
+	building := make(map[reflect.Type]*typeCodec)
+	c := buildCodec(t, building)
+	for bt, bc := range building {
+		codecCache.Store(bt, bc)
+	}
+	return c
+}
+
+// buildCodec 编译类型 t 的编解码计划，building 用于处理递归类型
+func buildCodec(t reflect.Type, building map[reflect.Type]*typeCodec) *typeCodec {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*typeCodec)
+	}
+	if c, ok := building[t]; ok {
+		return c
+	}
+	c := &typeCodec{}
+	building[t] = c
+
+	if t == rawMessageType {
+		c.encode = encodeRawMessage
+		c.decode = decodeRawMessage
+		return c
+	}
+	enc, dec := marshalerCodec(t)
+	if enc == nil || dec == nil {
+		if isEnumType(t) {
+			c.encode, c.decode = enumCodec(t)
+		} else {
+			buildKindCodec(c, t, building)
+		}
+	}
+	if enc != nil {
+		c.encode = enc
+	}
+	if dec != nil {
+		c.decode = dec
+	}
+	return c
+}
+
+// buildKindCodec 按 t.Kind() 编译编解码计划
+func buildKindCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	switch t.Kind() {
+	case reflect.Bool:
+		c.encode = encodeBool
+		c.decode = decodeBool
+	case reflect.Int8:
+		c.encode = encodeInt8
+		c.decode = decodeInt8
+	case reflect.Uint8:
+		c.encode = encodeUint8
+		c.decode = decodeUint8
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.encode = encodeInt
+		c.decode = intDecoder(t.Kind())
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		c.encode = encodeUint
+		c.decode = uintDecoder(t.Kind())
+	case reflect.Float32:
+		c.encode = encodeFloat32
+		c.decode = decodeFloat32
+	case reflect.Float64:
+		c.encode = encodeFloat64
+		c.decode = decodeFloat64
+	case reflect.String:
+		c.encode = encodeString
+		c.decode = decodeString
+	case reflect.Slice:
+		buildSliceCodec(c, t, building)
+	case reflect.Array:
+		buildArrayCodec(c, t, building)
+	case reflect.Map:
+		buildMapCodec(c, t, building)
+	case reflect.Ptr:
+		buildPtrCodec(c, t, building)
+	case reflect.Interface:
+		c.encode = encodeInterface
+		c.decode = unsupportedDecoder(t)
+	case reflect.Struct:
+		buildStructCodec(c, t, building)
+	default:
+		c.encode = unsupportedEncoder(t)
+		c.decode = unsupportedDecoder(t)
+	}
+}
+
+func unsupportedEncoder(t reflect.Type) encodeFunc {
+	return func(e *Encoder, tag JceTag, v reflect.Value) error {
+		return fmt.Errorf("invalid type: %v to encode", t)
+	}
+}
+
+func unsupportedDecoder(t reflect.Type) decodeFunc {
+	return func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		return &UnmarshalError{t}
+	}
+}
+
+func encodeBool(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagBoolValue(tag, v.Bool())
+}
+
+func decodeBool(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeBool(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetBool(b)
+	return nil
+}
+
+func encodeInt8(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt8Value(tag, int8(v.Int()))
+}
+
+func decodeInt8(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeInt8(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(b))
+	return nil
+}
+
+func encodeUint8(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt8Value(tag, int8(v.Uint()))
+}
+
+func decodeUint8(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeUint8(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetUint(uint64(b))
+	return nil
+}
+
+func encodeInt(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt64Value(tag, v.Int())
+}
+
+func intDecoder(kind reflect.Kind) decodeFunc {
+	typeValue := Int64
+	switch kind {
+	case reflect.Int16:
+		typeValue = Int16
+	case reflect.Int32:
+		typeValue = Int32
+	}
+	return func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		b, err := d.decodeInteger(tag, required, typeValue)
+		if err != nil {
+			return err
+		}
+		v.SetInt(b)
+		return nil
+	}
+}
+
+func encodeUint(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt64Value(tag, int64(v.Uint()))
+}
+
+func uintDecoder(kind reflect.Kind) decodeFunc {
+	// 无符号类型按照下一级更宽的有符号类型读取
+	typeValue := Int64
+	switch kind {
+	case reflect.Uint16:
+		typeValue = Int32
+	}
+	return func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		b, err := d.decodeInteger(tag, required, typeValue)
+		if err != nil {
+			return err
+		}
+		v.SetUint(uint64(b))
+		return nil
+	}
+}
+
+func encodeFloat32(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagFloat32Value(tag, float32(v.Float()))
+}
+
+func decodeFloat32(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeFloat32(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetFloat(float64(b))
+	return nil
+}
+
+func encodeFloat64(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagFloat64Value(tag, v.Float())
+}
+
+func decodeFloat64(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeFloat64(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetFloat(b)
+	return nil
+}
+
+func encodeString(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagStringValue(tag, v.String())
+}
+
+func decodeString(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	b, err := d.decodeString(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetString(b)
+	return nil
+}
+
+func encodeInterface(e *Encoder, tag JceTag, v reflect.Value) error {
+	if v.IsNil() {
+		return fmt.Errorf("nil interface %v to encode, tag: %d", v.Type(), tag)
+	}
+	rv := v.Elem()
+	return codecFor(rv.Type()).encode(e, tag, rv)
+}
+
+func buildSliceCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	elem := buildCodec(t.Elem(), building)
+	isBytes := t.Elem().Kind() == reflect.Uint8
+	isStrings := t.Elem() == reflect.TypeOf("")
+
+	c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error {
+		if isBytes && v.Len() != 0 {
+			e.encodeHeaderTag(tag, SimpleList)
+			e.encodeHeaderTag(0, Int8)
+			e.encodeTagInt32Value(0, int32(v.Len()))
+			return e.write(v.Bytes())
+		}
+		e.encodeHeaderTag(tag, List)
+		e.encodeTagInt32Value(0, int32(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := elem.encode(e, 0, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return e.err
+	}
+
+	c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(t, 0, 0))
+		}
+		if isBytes {
+			var b []byte
+			if err := d.readBytes(&b, tag, required); err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		if isStrings {
+			var sv []string
+			if err := d.readStrings(&sv, tag, required); err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(sv))
+			return nil
+		}
+		flag, headType, headLen, err := d.skipToTag(tag)
+		if err != nil {
+			return err
+		}
+		if !flag {
+			if required {
+				return d.notExist()
+			}
+			return nil
+		}
+		if headType != List {
+			return d.mismatch(List, headType, headLen)
+		}
+		if err = d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		vectorSize, err := d.readSize()
+		if err != nil {
+			return err
+		}
+		// 元素个数不可信，超过预分配容量后按实际读到的元素逐个扩容
+		n := preallocSize(vectorSize)
+		sv := reflect.MakeSlice(t, n, n)
+		zero := reflect.Zero(t.Elem())
+		for i := 0; i < vectorSize; i++ {
+			if i >= n {
+				sv = reflect.Append(sv, zero)
+			}
+			if err = elem.decode(d, 0, true, sv.Index(i)); err != nil {
+				return d.pathError(err, "["+strconv.Itoa(i)+"]")
+			}
+		}
+		v.Set(sv)
+		return nil
+	}
+}
+
+// buildArrayCodec 定长数组按 vector 编码，[N]byte 为 SimpleList，解码时长度必须为 N
+func buildArrayCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	elem := buildCodec(t.Elem(), building)
+	isBytes := t.Elem().Kind() == reflect.Uint8
+	size := t.Len()
+
+	c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error {
+		if isBytes && size != 0 {
+			if !v.CanAddr() {
+				tmp := reflect.New(t).Elem()
+				tmp.Set(v)
+				v = tmp
+			}
+			e.encodeHeaderTag(tag, SimpleList)
+			e.encodeHeaderTag(0, Int8)
+			e.encodeTagInt32Value(0, int32(size))
+			return e.write(v.Bytes())
+		}
+		e.encodeHeaderTag(tag, List)
+		e.encodeTagInt32Value(0, int32(size))
+		for i := 0; i < size; i++ {
+			if err := elem.encode(e, 0, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return e.err
+	}
+
+	c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		if !v.CanSet() {
+			return &UnmarshalError{t}
+		}
+		if isBytes {
+			absent, err := d.optionalAbsent(tag, required)
+			if err != nil || absent {
+				return err
+			}
+			var b []byte
+			if err = d.readBytes(&b, tag, true); err != nil {
+				return err
+			}
+			if len(b) != size {
+				return arrayLengthError(size, len(b))
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		flag, headType, headLen, err := d.skipToTag(tag)
+		if err != nil {
+			return err
+		}
+		if !flag {
+			if required {
+				return d.notExist()
+			}
+			return nil
+		}
+		if headType != List {
+			return d.mismatch(List, headType, headLen)
+		}
+		if err = d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		vectorSize, err := d.readSize()
+		if err != nil {
+			return err
+		}
+		if vectorSize != size {
+			return arrayLengthError(size, vectorSize)
+		}
+		for i := 0; i < size; i++ {
+			if err = elem.decode(d, 0, true, v.Index(i)); err != nil {
+				return d.pathError(err, "["+strconv.Itoa(i)+"]")
+			}
+		}
+		return nil
+	}
+}
+
+func arrayLengthError(expected, actual int) error {
+	return fmt.Errorf("%w: expect %d, get %d", ErrJceDecodeArrayLength, expected, actual)
+}
+
+func buildMapCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	key := buildCodec(t.Key(), building)
+	elem := buildCodec(t.Elem(), building)
+
+	isStringMap := t == reflect.TypeOf(map[string]string(nil))
+
+	c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error {
+		if isStringMap && v.CanInterface() {
+			return e.writeStringMap(v.Interface().(map[string]string), tag)
+		}
+		if e.opts.Canonical {
+			return e.encodeCanonicalMap(tag, v, key, elem)
+		}
+		e.encodeHeaderTag(tag, Map)
+		e.encodeTagInt32Value(0, int32(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := key.encode(e, 0, iter.Key()); err != nil {
+				return err
+			}
+			if err := elem.encode(e, 1, iter.Value()); err != nil {
+				return err
+			}
+		}
+		return e.err
+	}
+
+	c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		flag, headType, headLen, err := d.skipToTag(tag)
+		if err != nil {
+			return err
+		}
+		if !flag {
+			if required {
+				return d.notExist()
+			}
+			return nil
+		}
+		if headType != Map {
+			return d.mismatch(Map, headType, headLen)
+		}
+		if err = d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		mapSize, err := d.readSize()
+		if err != nil {
+			return err
+		}
+		vm := reflect.MakeMapWithSize(t, preallocSize(mapSize))
+		for i := 0; i < mapSize; i++ {
+			kv := reflect.New(t.Key()).Elem()
+			vv := reflect.New(t.Elem()).Elem()
+			if err = key.decode(d, 0, true, kv); err != nil {
+				return d.pathError(err, "["+strconv.Itoa(i)+"]")
+			}
+			if err = elem.decode(d, 1, true, vv); err != nil {
+				return d.pathError(err, mapKeyPath(kv))
+			}
+			vm.SetMapIndex(kv, vv)
+		}
+		v.Set(vm)
+		return nil
+	}
+}
+
+// buildPtrCodec 指针作为结构体字段时表示可选字段：为 nil 时不编码，
+// 字段存在时才分配，见 encodeStructFields 和 decodeStructFields
+func buildPtrCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	elem := buildCodec(t.Elem(), building)
+
+	c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error {
+		if v.IsNil() {
+			return fmt.Errorf("nil pointer %v to encode, tag: %d", t, tag)
+		}
+		return elem.encode(e, tag, v.Elem())
+	}
+
+	c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		if !v.IsNil() {
+			return elem.decode(d, tag, required, v.Elem())
+		}
+		if !v.CanSet() {
+			return &UnmarshalError{t}
+		}
+		absent, err := d.optionalAbsent(tag, required)
+		if err != nil || absent {
+			return err
+		}
+		nv := reflect.New(t.Elem())
+		if err = elem.decode(d, tag, required, nv.Elem()); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	}
+}
+
+func buildStructCodec(c *typeCodec, t reflect.Type, building map[reflect.Type]*typeCodec) {
+	if reflect.PtrTo(t).Implements(structType) {
+		c.encode = encodeStructInterface
+		c.decode = decodeStructInterface
+		return
+	}
+
+	sfs, err := parseStructFields(t)
+	if err != nil {
+		c.err = err
+		c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error { return err }
+		c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error { return err }
+		return
+	}
+	c.unknown = unknownFieldIndex(t)
+	c.fields = make([]fieldCodec, len(sfs))
+	for i, sf := range sfs {
+		c.fields[i] = fieldCodec{
+			structField: sf,
+			codec:       buildCodec(t.Field(sf.index).Type, building),
+		}
+	}
+
+	c.encode = func(e *Encoder, tag JceTag, v reflect.Value) error {
+		e.encodeHeaderTag(tag, StructBegin)
+		if err := e.encodeStructFields(v); err != nil {
+			return err
+		}
+		return e.encodeHeaderTag(0, StructEnd)
+	}
+
+	c.decode = func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		if !v.CanSet() {
+			return &UnmarshalError{t}
+		}
+		return d.readTaggedStruct(v, tag, required)
+	}
+}
+
+func encodeStructInterface(e *Encoder, tag JceTag, v reflect.Value) error {
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type())
+		tmp.Elem().Set(v)
+		v = tmp.Elem()
+	}
+	return e.WriteStruct(v.Addr().Interface().(Struct), tag)
+}
+
+func decodeStructInterface(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	if !v.CanAddr() {
+		return &UnmarshalError{v.Type()}
+	}
+	return d.readStruct(v.Addr().Interface().(Struct), tag, required)
+}
+
+// mapKeyPath map 中 key 对应的值在错误路径中的表示
+func mapKeyPath(key reflect.Value) string {
+	if !key.CanInterface() {
+		return "[key]"
+	}
+	return fmt.Sprintf("[key=%v]", key.Interface())
+}
@@ -5,51 +5,203 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"strconv"
+	"unsafe"
 )
 
+// Decoder 解码器，用于反序列化
 type Decoder struct {
 	reader *bufio.Reader
-	order  binary.ByteOrder
+	// src 不为空时直接从内存中读取，reader 不使用
+	src   *sliceReader
+	order binary.ByteOrder
+	state *decodeState
+	// unknown 不为空时跳过的未知字段追加到其中，见 CaptureUnknown
+	unknown *UnknownFields
+	// scratch 流式读取定长整数时使用，避免分配
+	scratch [8]byte
 }
 
+// NewDecoder 创建解码器，r 为 Struct.Decode 收到的 Reader 时复用外层 Decoder 的缓冲和解码限制
 func NewDecoder(r io.Reader) *Decoder {
+	if dr, ok := r.(*decoderReader); ok {
+		return &Decoder{
+			reader: dr.d.reader,
+			src:    dr.d.src,
+			order:  dr.d.order,
+			state:  dr.d.state,
+		}
+	}
 	return &Decoder{
 		reader: bufio.NewReader(r),
 		order:  binary.BigEndian,
+		state:  &decodeState{},
+	}
+}
+
+// NewBytesDecoder 创建直接从 data 读取的解码器，不经过 bufio 也不逐字段分配内存。
+// ReadBytes 返回的是 data 的子切片，解码结果使用期间不能修改 data
+func NewBytesDecoder(data []byte) *Decoder {
+	return &Decoder{
+		src:   &sliceReader{buf: data},
+		order: binary.BigEndian,
+		state: &decodeState{},
+	}
+}
+
+// NewBytesDecoderWithOptions 带解码限制的 NewBytesDecoder
+func NewBytesDecoderWithOptions(data []byte, opts DecoderOptions) *Decoder {
+	d := NewBytesDecoder(data)
+	d.state.opts = opts
+	return d
+}
+
+// DecodeStruct 解码不带 StructBegin/StructEnd 的顶层结构体，如 RequestPacket，
+// v.Decode 中创建的 Decoder 共享 d 的输入和解码限制
+func (d *Decoder) DecodeStruct(v Struct) error {
+	return rootError(v.Decode(d.nested()), v)
+}
+
+// sliceReader NewBytesDecoder 的输入，嵌套的 Decoder 共享读取位置
+type sliceReader struct {
+	buf []byte
+	off int
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.buf) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// next 返回接下来的 n 个字节，peek 为 false 时前移读取位置
+func (r *sliceReader) next(n int, peek bool) ([]byte, error) {
+	if n > len(r.buf)-r.off {
+		if peek {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.off : r.off+n : r.off+n]
+	if !peek {
+		r.off += n
 	}
+	return b, nil
 }
 
 func (d *Decoder) readByte() (byte, error) {
-	b := make([]byte, 1)
-	n, err := d.reader.Read(b)
-	if err != nil {
+	if err := d.checkTotal(1); err != nil {
 		return 0, err
 	}
-	if n != 1 {
-		return 0, ErrBufferPeekOverflow
+	var (
+		b   byte
+		err error
+	)
+	if d.src != nil {
+		if d.src.off >= len(d.src.buf) {
+			return 0, io.EOF
+		}
+		b = d.src.buf[d.src.off]
+		d.src.off++
+	} else if b, err = d.reader.ReadByte(); err != nil {
+		return 0, err
 	}
-	return b[0], nil
+	d.state.total++
+	return b, nil
 }
 
+// maxChunkSize 大于该长度的数据分段读取，避免按不可信的长度一次性分配
+const maxChunkSize = 64 << 10
+
+// readNBytes 读取 n 个字节，从内存解码时返回的是输入的子切片
 func (d *Decoder) readNBytes(n int, peek ...bool) (b []byte, err error) {
-	var count int
-	if len(peek) > 0 && peek[0] {
+	isPeek := len(peek) > 0 && peek[0]
+	if !isPeek {
+		if err = d.checkTotal(n); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case d.src != nil:
+		b, err = d.src.next(n, isPeek)
+	case isPeek:
 		b, err = d.reader.Peek(n)
-		count = len(b)
-	} else {
+		if err == nil && len(b) != n {
+			err = ErrBufferPeekOverflow
+		}
+	case n <= maxChunkSize:
 		b = make([]byte, n)
-		count, err = d.reader.Read(b)
+		_, err = io.ReadFull(d.reader, b)
+	default:
+		for len(b) < n && err == nil {
+			chunk := n - len(b)
+			if chunk > maxChunkSize {
+				chunk = maxChunkSize
+			}
+			b = append(b, make([]byte, chunk)...)
+			_, err = io.ReadFull(d.reader, b[len(b)-chunk:])
+		}
 	}
 	if err != nil {
+		if err == io.EOF && !isPeek {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	if count != n {
-		return nil, ErrBufferPeekOverflow
+	if !isPeek {
+		d.state.total += int64(n)
 	}
 	return b, nil
 }
 
+// readFixed 读取 n(<= 8) 个字节，返回的切片只在下一次读取前有效
+func (d *Decoder) readFixed(n int) ([]byte, error) {
+	if d.src != nil {
+		return d.readNBytes(n)
+	}
+	if err := d.checkTotal(n); err != nil {
+		return nil, err
+	}
+	b := d.scratch[:n]
+	if _, err := io.ReadFull(d.reader, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	d.state.total += int64(n)
+	return b, nil
+}
+
+func (d *Decoder) readUint16() (uint16, error) {
+	b, err := d.readFixed(2)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint16(b), nil
+}
+
+func (d *Decoder) readUint32() (uint32, error) {
+	b, err := d.readFixed(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+func (d *Decoder) readUint64() (uint64, error) {
+	b, err := d.readFixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint64(b), nil
+}
+
 func (d *Decoder) decodeBool(tag JceTag, required bool) (bool, error) {
 	v, err := d.decodeInteger(tag, required, Int8)
 	if err != nil {
@@ -91,13 +243,13 @@ func (d *Decoder) decodeInt64(tag JceTag, required bool) (int64, error) {
 }
 
 func (d *Decoder) decodeInteger(tag JceTag, required bool, typeValue JceEncodeType) (int64, error) {
-	flag, headType, _, err := d.skipToTag(tag)
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return 0, err
 	}
 	if flag {
 		if headType > typeValue && headType != Zero {
-			return 0, fmt.Errorf("read 'Integer' type mismatch, tag: %d, get type: %d", tag, headType)
+			return 0, d.mismatch(typeValue, headType, headLen)
 		}
 		switch headType {
 		case Zero:
@@ -110,23 +262,20 @@ func (d *Decoder) decodeInteger(tag JceTag, required bool, typeValue JceEncodeTy
 			v := int8(next)
 			return int64(v), nil
 		case Int16:
-			v := int16(0)
-			err := binary.Read(d.reader, d.order, &v)
-			return int64(v), err
+			v, err := d.readUint16()
+			return int64(int16(v)), err
 		case Int32:
-			v := int32(0)
-			err := binary.Read(d.reader, d.order, &v)
-			return int64(v), err
+			v, err := d.readUint32()
+			return int64(int32(v)), err
 		case Int64:
-			v := int64(0)
-			err := binary.Read(d.reader, d.order, &v)
-			return v, err
+			v, err := d.readUint64()
+			return int64(v), err
 		default:
-			return 0, fmt.Errorf("read 'Integer' type mismatch, tag: %d, get type: %d", tag, headType)
+			return 0, d.mismatch(typeValue, headType, headLen)
 		}
 	} else {
 		if required {
-			return 0, fmt.Errorf("'%d' require field not exist, tag:%d", typeValue, tag)
+			return 0, d.notExist()
 		}
 	}
 	return 0, nil
@@ -140,37 +289,35 @@ func (d *Decoder) decodeFloat64(tag JceTag, required bool) (float64, error) {
 }
 
 func (d *Decoder) decodeFloatDouble(tag JceTag, required bool, typeValue JceEncodeType) (float64, error) {
-	flag, headType, _, err := d.skipToTag(tag)
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return 0, err
 	}
 	if flag {
 		if headType > typeValue {
-			return 0, fmt.Errorf("read 'FloatDouble' type mismatch, tag: %d, get type: %d", tag, headType)
+			return 0, d.mismatch(typeValue, headType, headLen)
 		}
 		switch headType {
 		case Zero:
 			return 0, nil
 		case Float32:
-			v := float32(0)
-			err := binary.Read(d.reader, d.order, &v)
-			return float64(v), err
+			v, err := d.readUint32()
+			return float64(math.Float32frombits(v)), err
 		case Float64:
-			v := float64(0)
-			err := binary.Read(d.reader, d.order, &v)
-			return v, err
+			v, err := d.readUint64()
+			return math.Float64frombits(v), err
 		default:
-			return 0, fmt.Errorf("read 'Float32/Float64' type mismatch, tag: %d, get type: %d", tag, headType)
+			return 0, d.mismatch(typeValue, headType, headLen)
 		}
 	} else {
 		if required {
-			return 0, fmt.Errorf("float64 require field not exist, tag: %d", tag)
+			return 0, d.notExist()
 		}
 	}
 	return float64(0), nil
 }
 func (d *Decoder) decodeString(tag JceTag, required bool) (string, error) {
-	flag, headType, _, err := d.skipToTag(tag)
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return "", err
 	}
@@ -184,236 +331,96 @@ func (d *Decoder) decodeString(tag JceTag, required bool) (string, error) {
 			}
 			strLen = int(b)
 		case String4:
-			len := int32(0)
-			binary.Read(d.reader, d.order, &len)
-			strLen = int(len)
+			len, err := d.readUint32()
+			if err != nil {
+				return "", err
+			}
+			strLen = int(int32(len))
 		default:
-			return "", fmt.Errorf("read 'String' type mismatch, tag: %d, get type: %d", tag, headType)
+			return "", d.mismatch(String1, headType, headLen)
 		}
-		if strLen < 0 {
-			return "", ErrBufferPeekOverflow
+		if err := d.checkStringLength(strLen); err != nil {
+			return "", err
 		}
 		b, err := d.readNBytes(strLen)
 		if err != nil {
 			return "", err
 		}
+		if d.src != nil && d.state.opts.AliasStrings && len(b) > 0 {
+			return unsafe.String(&b[0], len(b)), nil
+		}
 		return string(b), nil
 	} else {
 		if required {
-			return "", fmt.Errorf("string require field not exist, tag:%d", tag)
+			return "", d.notExist()
 		}
 	}
 	return "", nil
 }
 
 func (d *Decoder) decode(tag JceTag, required bool, v *reflect.Value) error {
-	switch v.Type().Kind() {
-	case reflect.Bool:
-		b, err := d.decodeBool(tag, required)
-		if err == nil {
-			v.SetBool(b)
-		} else {
-			return err
-		}
-	case reflect.Int8:
-		b, err := d.decodeInt8(tag, required)
-		if err == nil {
-			v.SetInt(int64(b))
-		} else {
-			return err
-		}
-	case reflect.Uint8:
-		b, err := d.decodeUint8(tag, required)
-		if err == nil {
-			v.SetUint(uint64(b))
-		} else {
-			return err
-		}
-	case reflect.Int16:
-		b, err := d.decodeInt16(tag, required)
-		if err == nil {
-			v.SetInt(int64(b))
-		} else {
-			return err
-		}
-	case reflect.Uint16:
-		b, err := d.decodeUint16(tag, required)
-		if err == nil {
-			v.SetUint(uint64(b))
-		} else {
-			return err
-		}
-	case reflect.Int32:
-		b, err := d.decodeInt32(tag, required)
-		if err == nil {
-			v.SetInt(int64(b))
-		} else {
-			return err
-		}
-	case reflect.Uint32:
-		b, err := d.decodeUint32(tag, required)
-		if err == nil {
-			v.SetUint(uint64(b))
-		} else {
-			return err
-		}
-	case reflect.Int64:
-		b, err := d.decodeInt64(tag, required)
-		if err == nil {
-			v.SetInt(int64(b))
-		} else {
-			return err
-		}
-	case reflect.Uint64:
-		b, err := d.decodeInt64(tag, required)
-		if err == nil {
-			v.SetUint(uint64(b))
-		} else {
-			return err
-		}
-	case reflect.Float32:
-		b, err := d.decodeFloat32(tag, required)
-		if err == nil {
-			v.SetFloat(float64(b))
-		} else {
-			return err
-		}
-	case reflect.Float64:
-		b, err := d.decodeFloat64(tag, required)
-		if err == nil {
-			v.SetFloat(b)
-		} else {
-			return err
-		}
-	case reflect.String:
-		b, err := d.decodeString(tag, required)
-		if err == nil {
-			v.SetString(b)
-		} else {
-			return err
-		}
-	case reflect.Array, reflect.Slice:
-		if v.IsNil() {
-			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
-		}
-		switch v.Type().Elem().Kind() {
-		case reflect.Uint8:
-			var b []byte
-			err := d.ReadBytes(&b, tag, required)
-			if err != nil {
-				return err
-			}
-			v.SetBytes(b)
-			return nil
-		case reflect.String:
-			var sv []string
-			err := d.ReadStrings(&sv, tag, required)
-			if err != nil {
-				return err
-			}
-			v.Set(reflect.ValueOf(sv))
-			return nil
-		default:
-			flag, headType, _, err := d.skipToTag(tag)
-			if err != nil {
-				return err
-			}
-			if flag {
-				switch headType {
-				case List:
-					vectorSize, err := d.decodeInt32(0, true)
-					if err != nil {
-						return err
-					}
-					sv := reflect.MakeSlice(v.Type(), int(vectorSize), int(vectorSize))
-					for i := 0; i < int(vectorSize); i++ {
-						iv := sv.Index(i)
-						err = d.decode(0, true, &(iv))
-						if err != nil {
-							return err
-						}
-					}
-					v.Set(sv)
-				default:
-					return fmt.Errorf("read 'vector' type mismatch, tag: %d, get type: %d", tag, headType)
-				}
-			} else {
-				if required {
-					return fmt.Errorf("type require field not exist, tag: %d", tag)
-				}
-			}
+	return codecFor(v.Type()).decode(d, tag, required, *v)
+}
+
+// skipToTag 跳过 tag 之前的字段，字段存在时读取字段头并返回其类型和长度
+func (d *Decoder) skipToTag(tag JceTag) (bool, JceEncodeType, int, error) {
+	for {
+		nextHeadTag, nextHeadType, len, err := d.peekTypeTag()
+		if err == io.EOF {
+			// 顶层结构体没有 StructEnd，读到结尾即认为字段不存在
+			return false, 0, 0, nil
 		}
-	case reflect.Map:
-		flag, headType, _, err := d.skipToTag(tag)
 		if err != nil {
-			return err
+			return false, 0, 0, err
 		}
-		if flag {
-			switch headType {
-			case Map:
-				mapSize, err := d.decodeInt32(0, true)
-				if err != nil {
-					return err
-				}
-				vm := reflect.MakeMap(v.Type())
-				for i := 0; i < int(mapSize); i++ {
-					kv := reflect.New(v.Type().Key()).Elem()
-					vv := reflect.New(v.Type().Elem()).Elem()
-					err = d.decode(0, true, &(kv))
-					if err != nil {
-						return err
-					}
-					err = d.decode(1, true, &(vv))
-					if err != nil {
-						return err
-					}
-					vm.SetMapIndex(kv, vv)
-				}
-				v.Set(vm)
-			default:
-				return fmt.Errorf("read 'map' type mismatch, tag: %d, get type: %d", tag, headType)
-			}
-		} else {
-			if required {
-				return fmt.Errorf("require field not exist, tag:%d", tag)
-			}
+		if nextHeadType == StructEnd || tag < nextHeadTag {
+			return false, 0, 0, nil
 		}
-	case reflect.Ptr:
-		if v.IsNil() {
-			return &UnmarshalError{reflect.TypeOf(v)}
+		if tag == nextHeadTag {
+			if err = d.discard(len); err != nil {
+				return false, 0, 0, err
+			}
+			return true, nextHeadType, len, nil
 		}
-		xv := v.Elem()
-		return d.decode(tag, required, &xv)
-	case reflect.Struct:
-		ts, ok := v.Addr().Interface().(Struct)
-		if ok {
-			return d.ReadStruct(ts, tag, required)
+		if err = d.skipUnknown(nextHeadType, len); err != nil {
+			return false, 0, 0, err
 		}
-		return &UnmarshalError{reflect.TypeOf(v)}
-	default:
-		return &UnmarshalError{reflect.TypeOf(v)}
 	}
-	return nil
 }
-func (d *Decoder) skipToTag(tag JceTag) (bool, JceEncodeType, JceTag, error) {
+
+// HasField 判断 tag 字段是否存在，跳过之前的字段但不读取该字段。
+// 可选字段不存在时 ReadXxx 会写入零值，需要保留原来的值（如默认值）时先调用 HasField
+func (d *Decoder) HasField(tag JceTag) (bool, error) {
+	absent, err := d.optionalAbsent(tag, false)
+	if err != nil {
+		return false, d.fieldError(err, tag)
+	}
+	return !absent, nil
+}
+
+// optionalAbsent 判断可选字段是否不存在，跳过之前的字段但不读取该字段的字段头
+func (d *Decoder) optionalAbsent(tag JceTag, required bool) (bool, error) {
+	if required {
+		return false, nil
+	}
 	for {
 		nextHeadTag, nextHeadType, len, err := d.peekTypeTag()
+		if err == io.EOF {
+			return true, nil
+		}
 		if err != nil {
-			return false, 0, 0, err
+			return false, err
 		}
 		if nextHeadType == StructEnd || tag < nextHeadTag {
-			return false, 0, 0, nil
-		}
-		_, err = d.readNBytes(len)
-		if err != nil {
-			return false, 0, 0, err
+			return true, nil
 		}
 		if tag == nextHeadTag {
-			return true, nextHeadType, nextHeadTag, nil
+			return false, nil
+		}
+		if err = d.skipUnknown(nextHeadType, len); err != nil {
+			return false, err
 		}
-		d.skipField(nextHeadType)
 	}
-	// return false, 0, 0, nil
 }
 
 func (d *Decoder) peekTypeTag() (JceTag, JceEncodeType, int, error) {
@@ -441,7 +448,9 @@ func (d *Decoder) skipOneField() error {
 	if err != nil {
 		return err
 	}
-	d.readNBytes(length)
+	if err = d.discard(length); err != nil {
+		return err
+	}
 	return d.skipField(headType)
 }
 
@@ -451,7 +460,7 @@ func (d *Decoder) skipToStructEnd() error {
 		if err != nil {
 			return err
 		}
-		_, err = d.readNBytes(len)
+		err = d.discard(len)
 		if err != nil {
 			return err
 		}
@@ -467,43 +476,74 @@ func (d *Decoder) skipToStructEnd() error {
 	return nil
 }
 
+// discard 跳过 n 个字节，不分配内存
+func (d *Decoder) discard(n int) error {
+	if err := d.checkTotal(n); err != nil {
+		return err
+	}
+	if d.src != nil {
+		if _, err := d.src.next(n, false); err != nil {
+			return err
+		}
+		d.state.total += int64(n)
+		return nil
+	}
+	m, err := d.reader.Discard(n)
+	d.state.total += int64(m)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 func (d *Decoder) skipField(typeValue JceEncodeType) (err error) {
 	switch typeValue {
 	case Int8, Int16, Int32, Int64, Float32, Float64:
-		_, err = d.readNBytes(typeValue.Size())
+		err = d.discard(typeValue.Size())
 	case String1:
 		var b byte
 		b, err = d.readByte()
 		if err != nil {
 			return
 		}
-		_, err = d.readNBytes(int(b))
+		err = d.discard(int(b))
 	case String4:
-		len := uint32(0)
-		err = binary.Read(d.reader, d.order, &len)
+		var len uint32
+		len, err = d.readUint32()
 		if err != nil {
 			return
 		}
-		_, err = d.readNBytes(int(len))
+		if err = d.checkStringLength(int(int32(len))); err != nil {
+			return
+		}
+		err = d.discard(int(len))
 	case Map:
-		var size int32
-		size, err = d.decodeInt32(0, true)
+		if err = d.enter(); err != nil {
+			return
+		}
+		defer d.leave()
+		var size int
+		size, err = d.readSize()
 		if err != nil {
 			return
 		}
-		for i := int32(0); i < (size * 2); i++ {
+		for i := 0; i < size*2; i++ {
 			err = d.skipOneField()
 			if err != nil {
 				return
 			}
 		}
 	case List:
-		var size int32
-		size, err = d.decodeInt32(0, true)
+		if err = d.enter(); err != nil {
+			return
+		}
+		defer d.leave()
+		var size int
+		size, err = d.readSize()
 		if err != nil {
 			return
 		}
-		for i := int32(0); i < size; i++ {
+		for i := 0; i < size; i++ {
 			err = d.skipOneField()
 			if err != nil {
 				return
@@ -519,23 +559,27 @@ func (d *Decoder) skipField(typeValue JceEncodeType) (err error) {
 		if err != nil {
 			return
 		}
-		_, err = d.readNBytes(len)
+		err = d.discard(len)
 		if err != nil {
 			return
 		}
 		if headType != Int8 {
-			return fmt.Errorf("skipField with invalid type, type value: %d, %d", typeValue, headType)
+			return d.mismatch(Int8, headType, len)
 		}
 		size, err = d.decodeInt32(0, true)
 		if err != nil {
 			return
 		}
-		_, err = d.readNBytes(int(size))
+		if err = d.checkStringLength(int(size)); err != nil {
+			return
+		}
+		err = d.discard(int(size))
 	case StructBegin:
-		err = d.skipToStructEnd()
-		if err != nil {
+		if err = d.enter(); err != nil {
 			return
 		}
+		defer d.leave()
+		err = d.skipToStructEnd()
 	case StructEnd, Zero:
 		break
 	default:
@@ -547,7 +591,7 @@ func (d *Decoder) skipField(typeValue JceEncodeType) (err error) {
 func (d *Decoder) ReadByte(v *byte, tag JceTag, required bool) error {
 	tv, err := d.decodeInt8(tag, required)
 	if err != nil {
-		return err
+		return d.fieldError(err, tag)
 	}
 	*v = byte(tv)
 	return nil
@@ -556,7 +600,7 @@ func (d *Decoder) ReadByte(v *byte, tag JceTag, required bool) error {
 func (d *Decoder) ReadBool(v *bool, tag JceTag, required bool) error {
 	tv, err := d.decodeInt8(tag, required)
 	if err != nil {
-		return err
+		return d.fieldError(err, tag)
 	}
 	if tv == 0 {
 		*v = false
@@ -569,83 +613,92 @@ func (d *Decoder) ReadBool(v *bool, tag JceTag, required bool) error {
 func (d *Decoder) ReadInt8(v *int8, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeInt8(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadUint8(v *uint8, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeUint8(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadInt16(v *int16, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeInt16(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadUint16(v *uint16, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeUint16(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadUint32(v *uint32, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeUint32(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadInt32(v *int32, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeInt32(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadInt64(v *int64, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeInt64(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadFloat64(v *float64, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeFloat64(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 func (d *Decoder) ReadFloat32(v *float32, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeFloat32(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 
 func (d *Decoder) ReadString(v *string, tag JceTag, required bool) error {
 	var err error
 	*v, err = d.decodeString(tag, required)
-	return err
+	return d.fieldError(err, tag)
 }
 
 func (d *Decoder) ReadBytes(v *[]byte, tag JceTag, required bool) error {
-	flag, headType, _, err := d.skipToTag(tag)
+	return d.fieldError(d.readBytes(v, tag, required), tag)
+}
+
+func (d *Decoder) readBytes(v *[]byte, tag JceTag, required bool) error {
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return err
 	}
 	if !flag {
 		if required {
-			return fmt.Errorf("require field not exist, tag:%d", tag)
+			return d.notExist()
 		}
 		return nil
 	}
 	if headType != SimpleList && headType != List {
-		return fmt.Errorf("read 'vector<byte>' type mismatch, tag: %d, get type: %d", tag, headType)
+		return d.mismatch(SimpleList, headType, headLen)
 	}
 	if headType == SimpleList {
 		_, cheadType, clen, err := d.peekTypeTag()
 		if err != nil {
 			return err
 		}
-		d.readNBytes(clen)
+		if err = d.discard(clen); err != nil {
+			return err
+		}
 		if cheadType != Int8 {
-			return fmt.Errorf("type mismatch, tag: %d, type: %d, %d", tag, headType, cheadType)
+			return d.mismatch(Int8, cheadType, clen)
 		}
 	}
 	vlen, err := d.decodeInt32(0, true)
 	if err != nil {
 		return err
 	}
+	if err = d.checkStringLength(int(vlen)); err != nil {
+		return err
+	}
 	*v, err = d.readNBytes(int(vlen))
 	if err != nil {
 		return err
@@ -653,31 +706,40 @@ func (d *Decoder) ReadBytes(v *[]byte, tag JceTag, required bool) error {
 	return nil
 }
 func (d *Decoder) ReadStrings(v *[]string, tag JceTag, required bool) error {
-	flag, headType, _, err := d.skipToTag(tag)
+	return d.fieldError(d.readStrings(v, tag, required), tag)
+}
+
+func (d *Decoder) readStrings(v *[]string, tag JceTag, required bool) error {
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return err
 	}
 	if !flag {
 		if required {
-			return fmt.Errorf("require field not exist, tag:%d", tag)
+			return d.notExist()
 		}
 		return nil
 	}
 	if headType != List {
-		return fmt.Errorf("read 'vector<string>' type mismatch, tag: %d, get type: %d", tag, headType)
+		return d.mismatch(List, headType, headLen)
 	}
-	vlen, err := d.decodeInt32(0, true)
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	vlen, err := d.readSize()
 	if err != nil {
 		return err
 	}
-	sv := make([]string, int(vlen))
-	*v = sv
-	for i := 0; i < int(vlen); i++ {
-		err = d.ReadString(&(sv[i]), 0, true)
+	sv := make([]string, 0, preallocSize(vlen))
+	for i := 0; i < vlen; i++ {
+		s, err := d.decodeString(0, true)
 		if err != nil {
-			return err
+			return d.pathError(err, "["+strconv.Itoa(i)+"]")
 		}
+		sv = append(sv, s)
 	}
+	*v = sv
 	return nil
 }
 
@@ -686,7 +748,7 @@ func (d *Decoder) ReadMap(v interface{}, tag JceTag, required bool) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &UnmarshalError{reflect.TypeOf(v)}
 	}
-	return d.decode(tag, required, &rv)
+	return d.fieldError(d.decode(tag, required, &rv), tag)
 }
 
 func (d *Decoder) ReadVector(v interface{}, tag JceTag, required bool) error {
@@ -694,29 +756,36 @@ func (d *Decoder) ReadVector(v interface{}, tag JceTag, required bool) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &UnmarshalError{reflect.TypeOf(v)}
 	}
-	return d.decode(tag, required, &rv)
+	return d.fieldError(d.decode(tag, required, &rv), tag)
 }
 
 func (d *Decoder) ReadStruct(v Struct, tag JceTag, required bool) error {
-	flag, headType, _, err := d.skipToTag(tag)
+	return d.fieldError(d.readStruct(v, tag, required), tag)
+}
+
+func (d *Decoder) readStruct(v Struct, tag JceTag, required bool) error {
+	flag, headType, headLen, err := d.skipToTag(tag)
 	if err != nil {
 		return err
 	}
 	if !flag {
 		if required {
-			return fmt.Errorf("require field not exist, tag:%d, type %T", tag, v)
+			return d.notExist()
 		}
 		return nil
 	}
 	if headType != StructBegin {
-		return fmt.Errorf("read 'struct' type mismatch, tag: %d, get type: %d", tag, headType)
+		return d.mismatch(StructBegin, headType, headLen)
 	}
-	err = v.Decode(d.reader)
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	err = v.Decode(d.nested())
 	if err != nil {
 		return err
 	}
-	d.skipToStructEnd()
-	return nil
+	return d.skipToStructEnd()
 }
 
 func (d *Decoder) Decode(v interface{}, tag JceTag, required bool) error {
@@ -724,5 +793,5 @@ func (d *Decoder) Decode(v interface{}, tag JceTag, required bool) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &UnmarshalError{reflect.TypeOf(v)}
 	}
-	return d.decode(tag, required, &rv)
+	return d.fieldError(d.decode(tag, required, &rv), tag)
 }
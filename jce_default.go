@@ -0,0 +1,117 @@
+package gojce
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// defaultResetter 拼写正确的 ResetDefault，与 Message 的 ResetDefautlt 等价
+type defaultResetter interface {
+	ResetDefault()
+}
+
+type legacyResetter interface {
+	ResetDefautlt()
+}
+
+// ResetDefault 将 v 重置为默认值，v 为结构体指针。
+// 实现了 ResetDefault 或 ResetDefautlt 方法时直接调用；否则先清零，再按字段的 `default:"..."` 标注赋值，
+// 没有默认值的嵌套结构体字段同样递归重置
+//
+// default 标注支持 bool、整数、浮点数和 string 类型的字段，反射解码时可选字段不存在也会使用默认值，
+// 不存在的结构体字段同样重置
+func ResetDefault(v any) error {
+	switch m := v.(type) {
+	case defaultResetter:
+		m.ResetDefault()
+		return nil
+	case legacyResetter:
+		m.ResetDefautlt()
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &UnmarshalError{reflect.TypeOf(v)}
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+	return resetStruct(rv)
+}
+
+func resetStruct(v reflect.Value) error {
+	c := codecFor(v.Type())
+	if c.err != nil {
+		return c.err
+	}
+	v.Set(reflect.Zero(v.Type()))
+	for i := range c.fields {
+		f := &c.fields[i]
+		fv := v.Field(f.index)
+		if f.def.IsValid() {
+			fv.Set(f.def)
+			continue
+		}
+		if _, err := resetStructField(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetStructField 将结构体类型的字段重置为默认值，fv 不是结构体或没有 tag 标注时返回 false
+func resetStructField(fv reflect.Value) (bool, error) {
+	if fv.Kind() != reflect.Struct {
+		return false, nil
+	}
+	switch m := fv.Addr().Interface().(type) {
+	case defaultResetter:
+		m.ResetDefault()
+	case legacyResetter:
+		m.ResetDefautlt()
+	default:
+		if codecFor(fv.Type()).fields == nil {
+			return false, nil
+		}
+		return true, resetStruct(fv)
+	}
+	return true, nil
+}
+
+// parseDefault 将 default 标注转换为类型 t 的值
+func parseDefault(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return v, fmt.Errorf("unsupported type %v", t)
+	}
+	return v, nil
+}
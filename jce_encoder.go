@@ -3,7 +3,6 @@ package gojce
 import (
 	"bufio"
 	"encoding/binary"
-	"fmt"
 	"io"
 	"reflect"
 )
@@ -24,194 +23,160 @@ func (e *UnmarshalError) Error() string {
 }
 
 // Encoder 编码器，用于序列化
+//
+// 第一次写入失败的错误会被记录下来，之后的写入直接跳过，
+// 所有 WriteXxx 和 Flush 都会返回该错误
 type Encoder struct {
-	w     *bufio.Writer
+	w *bufio.Writer
+	// buf w 为空时编码结果直接追加到 buf
+	buf   []byte
 	order binary.ByteOrder
+	err   error
+	opts  EncoderOptions
+	// scratch 流式写入时用于拼接字段头和定长数值
+	scratch [16]byte
+	self    encoderWriter
 }
 
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{
+	if ew, ok := w.(*encoderWriter); ok {
+		// Struct.Encode 中创建的 Encoder 直接使用外层 Encoder
+		return ew.e
+	}
+	e := &Encoder{
 		w:     bufio.NewWriter(w),
 		order: binary.BigEndian,
 	}
+	e.self.e = e
+	return e
+}
+
+// NewBufferEncoder 创建将编码结果追加到 buf 的 Encoder，不经过 bufio，
+// 通过 Bytes 获取结果，Reset 后可以复用
+func NewBufferEncoder(buf []byte) *Encoder {
+	e := &Encoder{
+		buf:   buf,
+		order: binary.BigEndian,
+	}
+	e.self.e = e
+	return e
+}
+
+// Bytes 返回 NewBufferEncoder 编码的结果
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Reset 清除错误，之后的编码结果追加到 buf，只对 NewBufferEncoder 有效
+func (e *Encoder) Reset(buf []byte) {
+	e.buf = buf
+	e.err = nil
+}
+
+// EncodeStruct 编码不带 StructBegin/StructEnd 的顶层结构体，如 RequestPacket，
+// v.Encode 中创建的 Encoder 直接写入 e
+func (e *Encoder) EncodeStruct(v Struct) error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.setErr(v.Encode(&e.self))
+}
+
+// encoderWriter 传给 Struct.Encode 的 Writer，NewEncoder 识别后返回外层 Encoder
+type encoderWriter struct {
+	e *Encoder
+}
+
+func (w *encoderWriter) Write(p []byte) (int, error) {
+	if err := w.e.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Err 返回第一次写入失败的错误
+func (e *Encoder) Err() error {
+	return e.err
 }
 
 func (e *Encoder) Flush() error {
-	return e.w.Flush()
+	if e.err != nil || e.w == nil {
+		return e.err
+	}
+	e.setErr(e.w.Flush())
+	return e.err
 }
 
-func (e *Encoder) encodeHeaderTag(tag JceTag, tagType JceEncodeType) {
-	if tag < 15 {
-		b := byte((uint8(tag) << 4) + uint8(tagType))
-		e.w.Write([]byte{b})
-	} else {
-		b1 := byte(tagType + 240)
-		b2 := byte(tag)
-		e.w.Write([]byte{b1, b2})
+// setErr 记录第一个错误并返回当前错误
+func (e *Encoder) setErr(err error) error {
+	if e.err == nil && err != nil {
+		e.err = err
 	}
+	return e.err
 }
 
-func (e *Encoder) encodeTagBoolValue(tag JceTag, bv bool) error {
-	if !bv {
-		e.encodeHeaderTag(tag, Zero)
-	} else {
-		e.encodeHeaderTag(tag, Int8)
-		e.w.Write([]byte{byte(1)})
+func (e *Encoder) write(p []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.w == nil {
+		e.buf = append(e.buf, p...)
+		return nil
 	}
-	return nil
+	_, err := e.w.Write(p)
+	return e.setErr(err)
 }
-func (e *Encoder) encodeTagInt8Value(tag JceTag, v int8) error {
-	if v == 0 {
-		e.encodeHeaderTag(tag, Zero)
-	} else {
-		e.encodeHeaderTag(tag, Int8)
-		e.w.Write([]byte{byte(v)})
+
+func (e *Encoder) writeString(s string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.w == nil {
+		e.buf = append(e.buf, s...)
+		return nil
 	}
-	return nil
+	_, err := e.w.WriteString(s)
+	return e.setErr(err)
+}
+
+func (e *Encoder) encodeHeaderTag(tag JceTag, tagType JceEncodeType) error {
+	return e.write(AppendHead(e.scratch[:0], tag, tagType))
+}
+
+func (e *Encoder) encodeTagBoolValue(tag JceTag, bv bool) error {
+	return e.write(AppendBool(e.scratch[:0], bv, tag))
+}
+func (e *Encoder) encodeTagInt8Value(tag JceTag, v int8) error {
+	return e.write(AppendInt8(e.scratch[:0], v, tag))
 }
 func (e *Encoder) encodeTagInt16Value(tag JceTag, v int16) error {
-	if v >= (-128) && v <= 127 {
-		return e.encodeTagInt8Value(tag, int8(v))
-	} else {
-		e.encodeHeaderTag(tag, Int16)
-		binary.Write(e.w, e.order, v)
-	}
-	return nil
+	return e.write(AppendInt16(e.scratch[:0], v, tag))
 }
 func (e *Encoder) encodeTagInt32Value(tag JceTag, v int32) error {
-	if v >= (-32768) && v <= 32767 {
-		return e.encodeTagInt16Value(tag, int16(v))
-	} else {
-		e.encodeHeaderTag(tag, Int32)
-		binary.Write(e.w, e.order, v)
-	}
-	return nil
+	return e.write(AppendInt32(e.scratch[:0], v, tag))
 }
 func (e *Encoder) encodeTagInt64Value(tag JceTag, v int64) error {
-	if v >= (-2147483647-1) && v <= 2147483647 {
-		return e.encodeTagInt32Value(tag, int32(v))
-	} else {
-		e.encodeHeaderTag(tag, Int64)
-		binary.Write(e.w, e.order, v)
-	}
-	return nil
+	return e.write(AppendInt64(e.scratch[:0], v, tag))
 }
 func (e *Encoder) encodeTagFloat32Value(tag JceTag, v float32) error {
-	e.encodeHeaderTag(tag, Float32)
-	binary.Write(e.w, e.order, v)
-	return nil
+	return e.write(AppendFloat32(e.scratch[:0], v, tag))
 }
 func (e *Encoder) encodeTagFloat64Value(tag JceTag, v float64) error {
-	e.encodeHeaderTag(tag, Float64)
-	binary.Write(e.w, e.order, v)
-	return nil
+	return e.write(AppendFloat64(e.scratch[:0], v, tag))
 }
 
 func (e *Encoder) encodeTagStringValue(tag JceTag, str string) error {
-	if len(str) > 255 {
-		e.encodeHeaderTag(tag, String4)
-		slen := uint32(len(str))
-		binary.Write(e.w, e.order, slen)
-	} else {
-		e.encodeHeaderTag(tag, String1)
-		e.w.Write([]byte{byte(len(str))})
+	if err := e.write(appendStringHead(e.scratch[:0], len(str), tag)); err != nil {
+		return err
 	}
-	e.w.Write([]byte(str))
-	return nil
+	return e.writeString(str)
 }
 
 func (e *Encoder) encodeValueWithTag(tag JceTag, v *reflect.Value) error {
-	switch v.Type().Kind() {
-	case reflect.Bool:
-		bv := v.Bool()
-		return e.encodeTagBoolValue(tag, bv)
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
-		return e.encodeTagInt64Value(tag, v.Int())
-	case reflect.Uint8:
-		return e.encodeTagInt8Value(tag, int8(v.Uint()))
-	case reflect.Int8:
-		return e.encodeTagInt8Value(tag, int8(v.Int()))
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return e.encodeTagInt64Value(tag, int64(v.Uint()))
-	case reflect.String:
-		str := v.String()
-		return e.encodeTagStringValue(tag, str)
-	case reflect.Float32:
-		return e.encodeTagFloat32Value(tag, float32(v.Float()))
-	case reflect.Float64:
-		return e.encodeTagFloat64Value(tag, v.Float())
-	case reflect.Array, reflect.Slice:
-		if v.IsNil() {
-			vv := reflect.MakeSlice(v.Type(), 0, 0)
-			v = &vv
-		}
-		if v.Len() != 0 && reflect.Uint8 == v.Index(0).Type().Kind() {
-			e.encodeHeaderTag(tag, SimpleList)
-			e.encodeHeaderTag(0, Int8)
-			e.encodeTagInt32Value(0, int32(v.Len()))
-			e.w.Write(v.Bytes())
-		} else {
-			e.encodeHeaderTag(tag, List)
-			e.encodeTagInt32Value(0, int32(v.Len()))
-			for i := 0; i < v.Len(); i++ {
-				vv := v.Index(i)
-				ts, ok := vv.Addr().Interface().(Struct)
-				if ok {
-					e.WriteStruct(ts, 0)
-				} else {
-					e.encodeValueWithTag(0, &vv)
-				}
-			}
-		}
-		return nil
-	case reflect.Map:
-		e.encodeHeaderTag(tag, Map)
-		if v.IsNil() {
-			e.encodeTagInt32Value(0, 0)
-		} else {
-			ks := v.MapKeys()
-			e.encodeTagInt32Value(0, int32(len(ks)))
-			for i := 0; i < len(ks); i++ {
-				e.encodeValueWithTag(0, &(ks[i]))
-				vv := v.MapIndex(ks[i])
-				e.encodeValueWithTag(1, &vv)
-			}
-		}
-		return nil
-	case reflect.Ptr:
-		// XXX: 检查性能
-		rv := reflect.Indirect(*v)
-		return e.encodeValueWithTag(tag, &rv)
-	case reflect.Interface:
-		rv := reflect.ValueOf(v.Interface())
-		return e.encodeValueWithTag(tag, &rv)
-	case reflect.Struct:
-		e.encodeHeaderTag(tag, StructBegin)
-		if reflect.PtrTo(v.Type()).Implements(structType) {
-			if v.CanAddr() {
-				ts := v.Addr().Interface().(Struct)
-				ts.Encode(e.w)
-			} else {
-				tmp := reflect.New(v.Type())
-				tmp.Elem().Set(*v)
-				ts := tmp.Interface().(Struct)
-				ts.Encode(e.w)
-			}
-		} else {
-			return fmt.Errorf("invalid type: %v to encode", v.Type())
-		}
-		// num := v.NumField()
-		// for i := 0; i < num; i++ {
-		// 	fv := v.Field(i)
-		// 	tagstr := v.Type().Field(i).Tag.Get("tag")
-		// 	if len(tagstr) > 0 {
-		// 		tag, _ := strconv.Atoi(tagstr)
-		// 		encodeValueWithTag(writer, uint8(tag), &fv)
-		// 	}
-		// }
-		e.encodeHeaderTag(0, StructEnd)
+	if e.err != nil {
+		return e.err
 	}
-	return nil
+	return e.setErr(codecFor(v.Type()).encode(e, tag, *v))
 }
 
 type Struct interface {
@@ -220,88 +185,88 @@ type Struct interface {
 }
 
 func (e *Encoder) WriteStruct(v Struct, tag JceTag) error {
-	e.encodeHeaderTag(tag, StructBegin)
-	v.Encode(e.w)
-	e.encodeHeaderTag(0, StructEnd)
-	return nil
+	if err := e.encodeHeaderTag(tag, StructBegin); err != nil {
+		return err
+	}
+	if err := e.setErr(v.Encode(&e.self)); err != nil {
+		return err
+	}
+	return e.encodeHeaderTag(0, StructEnd)
 }
 func (e *Encoder) WriteInt64(v int64, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteUint32(v uint32, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteInt32(v int32, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteUint16(v uint16, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteInt16(v int16, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteUint8(v uint8, tag JceTag) error {
-	e.encodeTagInt64Value(tag, int64(v))
-	return nil
+	return e.encodeTagInt64Value(tag, int64(v))
 }
 func (e *Encoder) WriteInt8(v int8, tag JceTag) error {
-	e.encodeTagInt8Value(tag, v)
-	return nil
+	return e.encodeTagInt8Value(tag, v)
 }
 func (e *Encoder) WriteBool(v bool, tag JceTag) error {
 	if v {
-		e.encodeTagInt8Value(tag, 1)
-	} else {
-		e.encodeTagInt8Value(tag, 0)
+		return e.encodeTagInt8Value(tag, 1)
 	}
-
-	return nil
+	return e.encodeTagInt8Value(tag, 0)
 }
 func (e *Encoder) WriteFloat32(v float32, tag JceTag) error {
-	e.encodeTagFloat32Value(tag, v)
-	return nil
+	return e.encodeTagFloat32Value(tag, v)
 }
 func (e *Encoder) WriteFloat64(v float64, tag JceTag) error {
-	e.encodeTagFloat64Value(tag, v)
-	return nil
+	return e.encodeTagFloat64Value(tag, v)
 }
 func (e *Encoder) WriteByte(v byte, tag JceTag) error {
-	e.encodeTagInt8Value(tag, int8(v))
-	return nil
+	return e.encodeTagInt8Value(tag, int8(v))
 }
 
 func (e *Encoder) WriteBytes(v []uint8, tag JceTag) error {
-	e.encodeHeaderTag(tag, SimpleList)
-	e.encodeHeaderTag(0, Int8)
-	e.WriteInt32(int32(len(v)), 0)
-	e.w.Write(v)
-	return nil
+	if err := e.write(appendBytesHead(e.scratch[:0], len(v), tag)); err != nil {
+		return err
+	}
+	return e.write(v)
 }
 
 func (e *Encoder) WriteString(v string, tag JceTag) error {
-	if len(v) > 255 {
-		e.encodeHeaderTag(tag, String4)
-		vlen := uint32(len(v))
-		binary.Write(e.w, e.order, vlen)
-	} else {
-		e.encodeHeaderTag(tag, String1)
-		e.w.Write([]byte{byte(len(v))})
-	}
-	e.w.Write([]byte(v))
-	return nil
+	return e.encodeTagStringValue(tag, v)
 }
 func (e *Encoder) WriteStrings(v []string, tag JceTag) error {
 	e.encodeHeaderTag(tag, List)
 	e.WriteInt32(int32(len(v)), 0)
 	for _, s := range v {
-		e.WriteString(s, 0)
+		if err := e.WriteString(s, 0); err != nil {
+			return err
+		}
+	}
+	return e.err
+}
+
+// writeStringMap map<string, string> 不经过反射编码
+func (e *Encoder) writeStringMap(m map[string]string, tag JceTag) error {
+	e.encodeHeaderTag(tag, Map)
+	e.encodeTagInt32Value(0, int32(len(m)))
+	if e.opts.Canonical {
+		for _, k := range sortedStringKeys(m) {
+			e.encodeTagStringValue(0, k)
+			e.encodeTagStringValue(1, m[k])
+		}
+		return e.err
 	}
-	return nil
+	for k, v := range m {
+		e.encodeTagStringValue(0, k)
+		e.encodeTagStringValue(1, v)
+	}
+	return e.err
 }
 
 // XXX: 暂不支持[]uint8
@@ -311,30 +276,28 @@ func (e *Encoder) WriteVector(v interface{}, tag JceTag) error {
 	if val.Kind() == reflect.Array || val.Kind() == reflect.Slice {
 		e.encodeHeaderTag(tag, List)
 		e.WriteInt32(int32(val.Len()), 0)
+		elem := codecFor(val.Type().Elem())
 		for i := 0; i < val.Len(); i++ {
-			vv := val.Index(i)
-			ts, ok := vv.Addr().Interface().(Struct)
-			if ok {
-				e.WriteStruct(ts, 0)
-			} else {
-				e.encodeValueWithTag(0, &vv)
+			if err := e.setErr(elem.encode(e, 0, val.Index(i))); err != nil {
+				return err
 			}
 		}
 	} else {
-		return ErrNotStruct
+		return e.setErr(ErrNotStruct)
 	}
-	return nil
+	return e.err
 }
 
 func (e *Encoder) WriteMap(v interface{}, tag JceTag) error {
+	if m, ok := v.(map[string]string); ok {
+		return e.writeStringMap(m, tag)
+	}
 	val := reflect.ValueOf(v)
-	e.encodeValueWithTag(tag, &val)
-	return nil
+	return e.encodeValueWithTag(tag, &val)
 }
 
 // XXX: []int8不是SimpleList
 func (e *Encoder) Encode(v interface{}, tag JceTag) error {
 	val := reflect.ValueOf(v)
-	e.encodeValueWithTag(tag, &val)
-	return nil
+	return e.encodeValueWithTag(tag, &val)
 }
@@ -0,0 +1,98 @@
+package gojce
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrJceDecodeUnknownEnum 开启 StrictEnums 时枚举的取值不在声明的范围内
+var ErrJceDecodeUnknownEnum = errors.New("unknown enum value")
+
+// Enum 枚举类型，以 int32 编码。jce2go 为 IDL 中的枚举生成实现，
+// 同时生成 XxxName、XxxValue 两个取值与名字互查的 map
+type Enum interface {
+	Int32() int32
+	String() string
+}
+
+// ValidEnum 可以判断取值是否声明过的枚举，用于 StrictEnums
+type ValidEnum interface {
+	Enum
+	IsValid() bool
+}
+
+var (
+	enumType      = reflect.TypeOf((*Enum)(nil)).Elem()
+	validEnumType = reflect.TypeOf((*ValidEnum)(nil)).Elem()
+)
+
+// ReadEnum 读取枚举字段，开启 StrictEnums 时检查取值，
+// 与 ReadInt32 相同，可选字段不存在时写入零值且不检查
+func ReadEnum[T interface {
+	~int32
+	Enum
+}](d *Decoder, v *T, tag JceTag, required bool) error {
+	absent, err := d.optionalAbsent(tag, required)
+	if err != nil {
+		return d.fieldError(err, tag)
+	}
+	if absent {
+		*v = 0
+		return nil
+	}
+	n, err := d.decodeInt32(tag, required)
+	if err != nil {
+		return d.fieldError(err, tag)
+	}
+	if d.state.opts.StrictEnums {
+		if err = checkEnum(T(n)); err != nil {
+			return d.fieldError(err, tag)
+		}
+	}
+	*v = T(n)
+	return nil
+}
+
+// checkEnum 检查 e 是否声明过的取值，没有实现 ValidEnum 时不检查
+func checkEnum(e Enum) error {
+	if ve, ok := e.(ValidEnum); ok && !ve.IsValid() {
+		return fmt.Errorf("%w: %d of %T", ErrJceDecodeUnknownEnum, e.Int32(), e)
+	}
+	return nil
+}
+
+// isEnumType t 是否实现了 Enum，指针和 interface 由元素和实际的类型处理
+func isEnumType(t reflect.Type) bool {
+	return t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(enumType)
+}
+
+// enumCodec 整数类型的枚举与对应的整数编码相同，解码时按 StrictEnums 检查取值；
+// 其他类型的枚举只能编码
+func enumCodec(t reflect.Type) (encodeFunc, decodeFunc) {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+	default:
+		return encodeEnum, unsupportedDecoder(t)
+	}
+	encode, decode := encodeInt, intDecoder(t.Kind())
+	if t.Kind() == reflect.Int8 {
+		encode, decode = encodeInt8, decodeInt8
+	}
+	if !t.Implements(validEnumType) {
+		return encode, decode
+	}
+	return encode, func(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+		if err := decode(d, tag, required, v); err != nil {
+			return err
+		}
+		if !d.state.opts.StrictEnums {
+			return nil
+		}
+		return checkEnum(v.Interface().(Enum))
+	}
+}
+
+func encodeEnum(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt32Value(tag, v.Interface().(Enum).Int32())
+}
@@ -0,0 +1,126 @@
+package gojce
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrJceDecodeTypeMismatch 字段的编码类型与期望的类型不一致
+var ErrJceDecodeTypeMismatch = errors.New("type mismatch")
+
+// ErrJceDecodeArrayLength vector 的长度与定长数组的长度不一致
+var ErrJceDecodeArrayLength = errors.New("array length mismatch")
+
+// DecodeError 解码错误，记录出错的位置，Err 可用 errors.Is/As 判断，
+// 如 ErrJceDecodeRequireNotExist、ErrJceDecodeTypeMismatch、ErrJceDecodeArrayLength、*LimitError、io.ErrUnexpectedEOF
+type DecodeError struct {
+	// Offset 出错时相对输入开始的字节偏移，类型不匹配时为字段头的位置
+	Offset int64
+	// Path 出错字段的 tag 路径，如 RequestPacket.9[key=AAA].1，
+	// [i] 为 vector 的下标或 map 的第 i 个 key，[key=K] 为 map 中 K 对应的值
+	Path string
+	// Expected、Actual 只在 Err 为 ErrJceDecodeTypeMismatch 时有效
+	Expected JceEncodeType
+	Actual   JceEncodeType
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("decode %s at offset %d: %v", e.Path, e.Offset, e.Err)
+	if e.Err == ErrJceDecodeTypeMismatch {
+		msg += fmt.Sprintf(", expect: %v, get: %v", e.Expected, e.Actual)
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// joinPath 在 path 前加上外层的路径 seg
+func joinPath(seg, path string) string {
+	if path == "" || path[0] == '[' {
+		return seg + path
+	}
+	return seg + "." + path
+}
+
+// mismatch 类型不匹配的错误，headLen 为已读取的字段头长度
+func (d *Decoder) mismatch(expected, actual JceEncodeType, headLen int) error {
+	return &DecodeError{
+		Offset:   d.state.total - int64(headLen),
+		Expected: expected,
+		Actual:   actual,
+		Err:      ErrJceDecodeTypeMismatch,
+	}
+}
+
+// notExist 必填字段不存在的错误
+func (d *Decoder) notExist() error {
+	return &DecodeError{Offset: d.state.total, Err: ErrJceDecodeRequireNotExist}
+}
+
+// pathError 为 err 加上外层的路径 seg，err 不是 DecodeError 时以当前读取位置创建
+func (d *Decoder) pathError(err error, seg string) error {
+	if err == nil {
+		return nil
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		de = &DecodeError{Offset: d.state.total, Err: err}
+		err = de
+	}
+	de.Path = joinPath(seg, de.Path)
+	return err
+}
+
+// fieldError 为字段 tag 的解码错误加上路径
+func (d *Decoder) fieldError(err error, tag JceTag) error {
+	if err == nil {
+		return nil
+	}
+	return d.pathError(err, strconv.Itoa(int(tag)))
+}
+
+// trimFieldPath 去掉 err 路径开头的 tag，自定义解码通过 ReadXxx 读取时已加上了自身的 tag，
+// 避免外层再次加上
+func trimFieldPath(err error, tag JceTag) error {
+	if err == nil {
+		return nil
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return err
+	}
+	seg := strconv.Itoa(int(tag))
+	if !strings.HasPrefix(de.Path, seg) {
+		return err
+	}
+	switch rest := de.Path[len(seg):]; {
+	case rest == "" || rest[0] == '[':
+		de.Path = rest
+	case rest[0] == '.':
+		de.Path = rest[1:]
+	}
+	return err
+}
+
+// rootError 为顶层结构体 v 的解码错误加上类型名
+func rootError(err error, v any) error {
+	if err == nil {
+		return nil
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return err
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	de.Path = joinPath(t.Name(), de.Path)
+	return err
+}
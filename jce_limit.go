@@ -0,0 +1,159 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrLimitExceeded 解码超出 DecoderOptions 的限制，可用 errors.Is 判断
+var ErrLimitExceeded = errors.New("decode limit exceeded")
+
+// DefaultMaxDepth DecoderOptions.MaxDepth 为 0 时的最大嵌套层数，防止恶意输入递归过深导致栈溢出
+const DefaultMaxDepth = 1000
+
+// DecoderOptions 解码选项，限制项用于处理不可信的输入，为 0 表示不限制（MaxDepth 除外）
+type DecoderOptions struct {
+	// MaxStringLength string 和 vector<byte> 的最大长度
+	MaxStringLength int
+	// MaxContainerElements vector 和 map 的最大元素个数
+	MaxContainerElements int
+	// MaxDepth 结构体、vector、map 的最大嵌套层数，<= 0 时使用 DefaultMaxDepth
+	MaxDepth int
+	// MaxTotalBytes 最多读取的字节数
+	MaxTotalBytes int64
+	// AliasStrings 只对 NewBytesDecoder 有效，解码出的 string 直接引用输入数据，
+	// 解码结果使用期间不能修改输入
+	AliasStrings bool
+	// StrictEnums 实现了 ValidEnum 的枚举取值不在声明的范围内时返回 ErrJceDecodeUnknownEnum
+	StrictEnums bool
+}
+
+// LimitError 超出解码限制时返回的错误
+type LimitError struct {
+	// Limit 超出的限制项，如 MaxStringLength
+	Limit string
+	Max   int64
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %d, get: %d", ErrLimitExceeded, e.Limit, e.Max, e.Value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// decodeState 同一次解码中嵌套的 Decoder 共享的状态
+type decodeState struct {
+	opts  DecoderOptions
+	depth int
+	total int64
+}
+
+// decoderReader 传给 Struct.Decode 的 Reader，NewDecoder 识别后复用父 Decoder 的缓冲和状态
+type decoderReader struct {
+	d *Decoder
+}
+
+func (r *decoderReader) Read(p []byte) (int, error) {
+	if err := r.d.checkTotal(len(p)); err != nil {
+		// 只允许读到剩余的字节数
+		remain := r.d.state.opts.MaxTotalBytes - r.d.state.total
+		if remain <= 0 {
+			return 0, err
+		}
+		p = p[:remain]
+	}
+	var (
+		n   int
+		err error
+	)
+	if r.d.src != nil {
+		n, err = r.d.src.Read(p)
+	} else {
+		n, err = r.d.reader.Read(p)
+	}
+	r.d.state.total += int64(n)
+	return n, err
+}
+
+// NewDecoderWithOptions 创建带解码限制的 Decoder，嵌套结构体的 Decoder 继承同样的限制
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	d := NewDecoder(r)
+	d.state = &decodeState{opts: opts}
+	return d
+}
+
+// UnmarshalWithOptions 带解码限制的 Unmarshal
+func UnmarshalWithOptions(data []byte, m Message, opts DecoderOptions) error {
+	return NewDecoderWithOptions(bytes.NewReader(data), opts).DecodeStruct(m)
+}
+
+// nested 返回传给 Struct.Decode 的 Reader
+func (d *Decoder) nested() io.Reader {
+	return &decoderReader{d}
+}
+
+// checkTotal 检查再读取 n 个字节是否超出 MaxTotalBytes
+func (d *Decoder) checkTotal(n int) error {
+	max := d.state.opts.MaxTotalBytes
+	if max > 0 && d.state.total+int64(n) > max {
+		return &LimitError{Limit: "MaxTotalBytes", Max: max, Value: d.state.total + int64(n)}
+	}
+	return nil
+}
+
+func (d *Decoder) checkStringLength(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid string length: %d", n)
+	}
+	if max := d.state.opts.MaxStringLength; max > 0 && n > max {
+		return &LimitError{Limit: "MaxStringLength", Max: int64(max), Value: int64(n)}
+	}
+	return nil
+}
+
+// readSize 读取 vector/map 的元素个数并检查限制
+func (d *Decoder) readSize() (int, error) {
+	n, err := d.decodeInt32(0, true)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid container size: %d", n)
+	}
+	if max := d.state.opts.MaxContainerElements; max > 0 && int(n) > max {
+		return 0, &LimitError{Limit: "MaxContainerElements", Max: int64(max), Value: int64(n)}
+	}
+	return int(n), nil
+}
+
+// enter 进入一层嵌套，成功时调用方需要在结束时调用 leave
+func (d *Decoder) enter() error {
+	d.state.depth++
+	max := d.state.opts.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if d.state.depth > max {
+		d.state.depth--
+		return &LimitError{Limit: "MaxDepth", Max: int64(max), Value: int64(d.state.depth + 1)}
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.state.depth--
+}
+
+// preallocSize 根据声明的元素个数预分配容量，避免按不可信的长度一次性分配
+func preallocSize(n int) int {
+	const maxPrealloc = 1024
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package gojce
+
+import (
+	"reflect"
+	"time"
+)
+
+// JceMarshaler 自定义编码的类型，MarshalJCE 以 tag 写入一个字段，
+// 反射编码遇到实现了该接口的类型（包括指针接收者）时优先调用
+type JceMarshaler interface {
+	MarshalJCE(e *Encoder, tag JceTag) error
+}
+
+// JceUnmarshaler 自定义解码的类型，UnmarshalJCE 读取 tag 字段，
+// 一般通过 d.ReadXxx 实现，字段不存在时的处理与 ReadXxx 相同
+type JceUnmarshaler interface {
+	UnmarshalJCE(d *Decoder, tag JceTag, required bool) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*JceMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*JceUnmarshaler)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+// marshalerCodec 返回 t 自定义的编解码函数，没有实现对应的接口时为 nil。
+// time.Time 编码为 Unix 毫秒数，零值为 0；time.Duration 编码为毫秒数
+func marshalerCodec(t reflect.Type) (encodeFunc, decodeFunc) {
+	switch t {
+	case timeType:
+		return encodeTime, decodeTime
+	case durationType:
+		return encodeDuration, decodeDuration
+	}
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		// 指针由元素类型处理，interface 按实际的类型编码
+		return nil, nil
+	}
+	var (
+		enc encodeFunc
+		dec decodeFunc
+	)
+	if t.Implements(marshalerType) {
+		enc = encodeMarshaler
+	} else if reflect.PtrTo(t).Implements(marshalerType) {
+		enc = encodeAddrMarshaler
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		dec = decodeUnmarshaler
+	}
+	return enc, dec
+}
+
+func encodeMarshaler(e *Encoder, tag JceTag, v reflect.Value) error {
+	return v.Interface().(JceMarshaler).MarshalJCE(e, tag)
+}
+
+func encodeAddrMarshaler(e *Encoder, tag JceTag, v reflect.Value) error {
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type())
+		tmp.Elem().Set(v)
+		v = tmp.Elem()
+	}
+	return v.Addr().Interface().(JceMarshaler).MarshalJCE(e, tag)
+}
+
+func decodeUnmarshaler(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	if !v.CanAddr() {
+		return &UnmarshalError{v.Type()}
+	}
+	err := v.Addr().Interface().(JceUnmarshaler).UnmarshalJCE(d, tag, required)
+	return trimFieldPath(err, tag)
+}
+
+func encodeTime(e *Encoder, tag JceTag, v reflect.Value) error {
+	tm := v.Interface().(time.Time)
+	if tm.IsZero() {
+		return e.encodeTagInt64Value(tag, 0)
+	}
+	return e.encodeTagInt64Value(tag, tm.UnixMilli())
+}
+
+// decodeTime 解码得到本地时区的时间，0 解码为零值
+func decodeTime(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	ms, err := d.decodeInt64(tag, required)
+	if err != nil {
+		return err
+	}
+	if ms == 0 {
+		v.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	v.Set(reflect.ValueOf(time.UnixMilli(ms)))
+	return nil
+}
+
+func encodeDuration(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.encodeTagInt64Value(tag, time.Duration(v.Int()).Milliseconds())
+}
+
+func decodeDuration(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	ms, err := d.decodeInt64(tag, required)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(time.Duration(ms) * time.Millisecond))
+	return nil
+}
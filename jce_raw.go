@@ -0,0 +1,116 @@
+package gojce
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RawMessage 未解码的单个字段的原始编码，字段头的 tag 固定为 0。
+// 作为字段类型时解码只截取字节不解析内容，编码时换成实际的 tag 后原样写回，
+// 适合转发时不关心内容的嵌套结构体、vector 和 map。
+// 可选字段为空表示不存在，编码时不写入；必填字段和 vector/map 的元素为空时编码返回错误
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// MarshalRaw 将 v 编码为 RawMessage，v 可以是任意可编码的值
+func MarshalRaw(v any) (RawMessage, error) {
+	e := NewBufferEncoder(nil)
+	if err := e.Encode(v, 0); err != nil {
+		return nil, err
+	}
+	return RawMessage(e.Bytes()), nil
+}
+
+// Type 字段的编码类型
+func (m RawMessage) Type() JceEncodeType {
+	if len(m) == 0 {
+		return Zero
+	}
+	return JceEncodeType(m[0] & 0x0F)
+}
+
+// Decode 将 m 解码到 v，v 为指针，与字段的声明类型相同
+func (m RawMessage) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &UnmarshalError{reflect.TypeOf(v)}
+	}
+	return NewBytesDecoder(m).decode(0, true, &rv)
+}
+
+// WriteRaw 以 tag 写入 v，v 为空时返回错误，可选字段为空时由调用方跳过
+func (e *Encoder) WriteRaw(v RawMessage, tag JceTag) error {
+	if len(v) == 0 {
+		return e.setErr(fmt.Errorf("empty RawMessage to encode, tag: %d", tag))
+	}
+	if v[0]>>4 != 0 {
+		return e.setErr(fmt.Errorf("invalid RawMessage head 0x%02x, tag: %d", v[0], tag))
+	}
+	if err := e.encodeHeaderTag(tag, v.Type()); err != nil {
+		return err
+	}
+	return e.write(v[1:])
+}
+
+// ReadRaw 读取 tag 字段的原始编码，字段不存在时不修改 v
+func (d *Decoder) ReadRaw(v *RawMessage, tag JceTag, required bool) error {
+	return d.fieldError(d.readRaw(v, tag, required), tag)
+}
+
+func (d *Decoder) readRaw(v *RawMessage, tag JceTag, required bool) error {
+	absent, err := d.optionalAbsent(tag, false)
+	if err != nil {
+		return err
+	}
+	if absent {
+		if required {
+			return d.notExist()
+		}
+		return nil
+	}
+	if d.src != nil {
+		// 从内存读取时直接截取，不解析内容
+		_, headType, headLen, err := d.peekTypeTag()
+		if err != nil {
+			return err
+		}
+		if err = d.discard(headLen); err != nil {
+			return err
+		}
+		start := d.src.off
+		if err = d.skipField(headType); err != nil {
+			return err
+		}
+		raw := make(RawMessage, 0, 1+d.src.off-start)
+		raw = AppendHead(raw, 0, headType)
+		*v = append(raw, d.src.buf[start:d.src.off]...)
+		return nil
+	}
+	val, err := d.readAnyValue()
+	if err != nil {
+		return err
+	}
+	val.raw.head.long = false
+	raw, err := appendValue(nil, 0, &val)
+	if err != nil {
+		return err
+	}
+	*v = raw
+	return nil
+}
+
+func encodeRawMessage(e *Encoder, tag JceTag, v reflect.Value) error {
+	return e.WriteRaw(RawMessage(v.Bytes()), tag)
+}
+
+func decodeRawMessage(d *Decoder, tag JceTag, required bool, v reflect.Value) error {
+	var raw RawMessage
+	if err := d.readRaw(&raw, tag, required); err != nil {
+		return err
+	}
+	if raw != nil {
+		v.SetBytes(raw)
+	}
+	return nil
+}
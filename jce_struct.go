@@ -0,0 +1,174 @@
+package gojce
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// structField 带有 `tag:"N"` 标注的结构体字段
+type structField struct {
+	index    int
+	name     string
+	tag      JceTag
+	required bool
+	// def `default:"..."` 标注的默认值，没有标注时无效
+	def reflect.Value
+}
+
+// parseStructFields 解析结构体的 tag/required/default 标注，按 tag 升序返回
+func parseStructFields(t reflect.Type) ([]structField, error) {
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tagstr := sf.Tag.Get("tag")
+		if tagstr == "" || tagstr == "-" || !sf.IsExported() {
+			continue
+		}
+		tag, err := strconv.Atoi(tagstr)
+		if err != nil || tag < 0 || tag > 255 {
+			return nil, fmt.Errorf("invalid tag %q of field %s.%s", tagstr, t.Name(), sf.Name)
+		}
+		required, _ := strconv.ParseBool(sf.Tag.Get("required"))
+		var def reflect.Value
+		if defstr, ok := sf.Tag.Lookup("default"); ok {
+			if def, err = parseDefault(sf.Type, defstr); err != nil {
+				return nil, fmt.Errorf("invalid default %q of field %s.%s: %v", defstr, t.Name(), sf.Name, err)
+			}
+		}
+		fields = append(fields, structField{
+			index:    i,
+			name:     sf.Name,
+			tag:      JceTag(tag),
+			required: required,
+			def:      def,
+		})
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].tag < fields[j].tag
+	})
+	for i := 1; i < len(fields); i++ {
+		if fields[i].tag == fields[i-1].tag {
+			return nil, fmt.Errorf("duplicate tag %d of field %s.%s", fields[i].tag, t.Name(), fields[i].name)
+		}
+	}
+	return fields, nil
+}
+
+// encodeStructFields 按 tag 顺序编码结构体字段，不包含 StructBegin/StructEnd
+func (e *Encoder) encodeStructFields(v reflect.Value) error {
+	c := codecFor(v.Type())
+	if c.err != nil {
+		return c.err
+	}
+	var unknown UnknownFields
+	if c.unknown != nil {
+		unknown = UnknownFields(v.FieldByIndex(c.unknown).Bytes())
+	}
+	for i := range c.fields {
+		f := &c.fields[i]
+		if len(unknown) > 0 {
+			var err error
+			if unknown, err = e.WriteUnknownBefore(unknown, f.tag); err != nil {
+				return err
+			}
+		}
+		fv := v.Field(f.index)
+		if !f.required && omitEmpty(fv) {
+			continue
+		}
+		if err := f.codec.encode(e, f.tag, fv); err != nil {
+			return e.setErr(err)
+		}
+	}
+	return e.WriteUnknown(unknown)
+}
+
+// omitEmpty 可选字段是否不存在：nil 指针或空的 RawMessage，必填字段仍然编码并报错
+func omitEmpty(fv reflect.Value) bool {
+	switch {
+	case fv.Kind() == reflect.Ptr:
+		return fv.IsNil()
+	case fv.Type() == rawMessageType:
+		return fv.Len() == 0
+	}
+	return false
+}
+
+// decodeStructFields 按 tag 顺序解码结构体字段，不处理 StructBegin/StructEnd
+func (d *Decoder) decodeStructFields(v reflect.Value) error {
+	c := codecFor(v.Type())
+	if c.err != nil {
+		return c.err
+	}
+	// 嵌套的结构体使用同一个 Decoder，未知字段只保存到所属的结构体
+	saved := d.unknown
+	defer func() { d.unknown = saved }()
+	var unknown UnknownFields
+	if c.unknown != nil {
+		d.unknown = &unknown
+	} else {
+		d.unknown = nil
+	}
+	for i := range c.fields {
+		f := &c.fields[i]
+		absent, err := d.optionalAbsent(f.tag, f.required)
+		if err != nil {
+			return d.fieldError(err, f.tag)
+		}
+		if absent {
+			fv := v.Field(f.index)
+			switch {
+			case f.def.IsValid():
+				fv.Set(f.def)
+			default:
+				// 结构体字段与生成代码一样取 ResetDefault 的结果，其余字段（包括指针）置为零值
+				reset, err := resetStructField(fv)
+				if err != nil {
+					return d.fieldError(err, f.tag)
+				}
+				if !reset {
+					fv.Set(reflect.Zero(fv.Type()))
+				}
+			}
+			continue
+		}
+		if err := f.codec.decode(d, f.tag, f.required, v.Field(f.index)); err != nil {
+			return d.fieldError(err, f.tag)
+		}
+	}
+	if c.unknown == nil {
+		return nil
+	}
+	if err := d.ReadUnknown(); err != nil {
+		return err
+	}
+	v.FieldByIndex(c.unknown).SetBytes(unknown)
+	return nil
+}
+
+// readTaggedStruct 解码带 tag 标注的普通结构体
+func (d *Decoder) readTaggedStruct(v reflect.Value, tag JceTag, required bool) error {
+	flag, headType, headLen, err := d.skipToTag(tag)
+	if err != nil {
+		return err
+	}
+	if !flag {
+		if required {
+			return d.notExist()
+		}
+		return nil
+	}
+	if headType != StructBegin {
+		return d.mismatch(StructBegin, headType, headLen)
+	}
+	if err = d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	if err = d.decodeStructFields(v); err != nil {
+		return err
+	}
+	return d.skipToStructEnd()
+}
@@ -0,0 +1,108 @@
+package gojce
+
+import (
+	"io"
+	"reflect"
+)
+
+// UnknownFields 解码时跳过的未知字段的原始编码，按出现顺序保存。
+// 带 tag 标注的结构体包含一个 UnknownFields 类型的导出字段（不需要 tag 标注）时，
+// 解码会把未知字段保存到其中，编码时按 tag 顺序原样写回；生成代码通过 jce2go -unknown-fields 开启
+type UnknownFields []byte
+
+var unknownFieldsType = reflect.TypeOf(UnknownFields(nil))
+
+// unknownFieldIndex 返回结构体中 UnknownFields 字段的下标，没有时返回 nil
+func unknownFieldIndex(t reflect.Type) []int {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Type == unknownFieldsType && sf.IsExported() && sf.Tag.Get("tag") == "" {
+			return sf.Index
+		}
+	}
+	return nil
+}
+
+// CaptureUnknown 之后跳过的未知字段追加到 u，u 为 nil 时不再保存
+func (d *Decoder) CaptureUnknown(u *UnknownFields) {
+	d.unknown = u
+}
+
+// ReadUnknown 读取剩余的字段直到输入或结构体结束，全部追加到 CaptureUnknown 设置的 UnknownFields，
+// 没有设置时不做处理
+func (d *Decoder) ReadUnknown() error {
+	if d.unknown == nil {
+		return nil
+	}
+	for {
+		_, headType, headLen, err := d.peekTypeTag()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if headType == StructEnd {
+			return nil
+		}
+		if err = d.skipUnknown(headType, headLen); err != nil {
+			return err
+		}
+	}
+}
+
+// skipUnknown 跳过字段头尚未读取的字段，设置了 CaptureUnknown 时保存其原始编码
+func (d *Decoder) skipUnknown(headType JceEncodeType, headLen int) error {
+	if d.unknown == nil {
+		if err := d.discard(headLen); err != nil {
+			return err
+		}
+		return d.skipField(headType)
+	}
+	v, err := d.readAnyValue()
+	if err != nil {
+		return err
+	}
+	*d.unknown, err = appendValue(*d.unknown, v.raw.head.tag, &v)
+	return err
+}
+
+// nextUnknown 返回 u 中第一个字段的 tag 和编码长度
+func nextUnknown(u UnknownFields) (JceTag, int, error) {
+	d := NewBytesDecoder(u)
+	tag, headType, headLen, err := d.peekTypeTag()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = d.discard(headLen); err != nil {
+		return 0, 0, err
+	}
+	if err = d.skipField(headType); err != nil {
+		return 0, 0, err
+	}
+	return tag, d.src.off, nil
+}
+
+// WriteUnknownBefore 写入 u 开头 tag 小于 tag 的字段，返回剩余的部分
+func (e *Encoder) WriteUnknownBefore(u UnknownFields, tag JceTag) (UnknownFields, error) {
+	for len(u) > 0 && e.err == nil {
+		t, n, err := nextUnknown(u)
+		if err != nil {
+			return u, e.setErr(err)
+		}
+		if t >= tag {
+			break
+		}
+		e.write(u[:n])
+		u = u[n:]
+	}
+	return u, e.err
+}
+
+// WriteUnknown 写入 u 中剩余的全部字段
+func (e *Encoder) WriteUnknown(u UnknownFields) error {
+	return e.write(u)
+}
@@ -0,0 +1,388 @@
+package gojce
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Field 无类型解码得到的带 tag 的字段
+type Field struct {
+	Tag   JceTag
+	Value Value
+}
+
+// MapEntry Map 中的一个键值对
+type MapEntry struct {
+	Key   Value
+	Value Value
+}
+
+// Value 无类型的 JCE 值，Type 为编码类型，按 Type 使用对应的成员
+type Value struct {
+	Type JceEncodeType
+	// Int Zero、Int8、Int16、Int32、Int64 的值
+	Int int64
+	// Float Float32、Float64 的值
+	Float float64
+	// Bytes String1、String4、SimpleList 的内容，NewBytesDecoder 解码时引用输入数据
+	Bytes []byte
+	// List List 的元素
+	List []Value
+	// Map Map 的元素，保持编码时的顺序
+	Map []MapEntry
+	// Fields StructBegin 的字段
+	Fields []Field
+
+	// raw 解码时记录的原始编码细节，用于原样写回
+	raw *valueRaw
+}
+
+// fieldHead 字段头，long 表示 tag < 15 时仍使用了两字节的字段头
+type fieldHead struct {
+	tag  JceTag
+	typ  JceEncodeType
+	long bool
+}
+
+type valueRaw struct {
+	head fieldHead
+	// size List、Map、SimpleList 的长度字段
+	size *Value
+	// elem SimpleList 的元素类型头
+	elem fieldHead
+	// end StructEnd 的字段头
+	end fieldHead
+}
+
+// DecodeAny 不依赖 Go 类型解码 r 中的所有字段，直到输入结束
+func DecodeAny(r io.Reader) ([]Field, error) {
+	return NewDecoder(r).DecodeAny()
+}
+
+// DecodeAny 不依赖 Go 类型解码剩余的所有字段，直到输入结束
+func (d *Decoder) DecodeAny() ([]Field, error) {
+	var fields []Field
+	for {
+		_, headType, _, err := d.peekTypeTag()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if headType == StructEnd {
+			return nil, fmt.Errorf("unexpected 'StructEnd' at top level")
+		}
+		v, err := d.readAnyValue()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Tag: v.raw.head.tag, Value: v})
+	}
+}
+
+func (d *Decoder) readHead() (fieldHead, error) {
+	tag, typ, n, err := d.peekTypeTag()
+	if err != nil {
+		return fieldHead{}, err
+	}
+	if err = d.discard(n); err != nil {
+		return fieldHead{}, err
+	}
+	return fieldHead{tag: tag, typ: typ, long: n == 2 && tag < 15}, nil
+}
+
+// readAnyValue 读取一个带字段头的值
+func (d *Decoder) readAnyValue() (Value, error) {
+	h, err := d.readHead()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Value{}, err
+	}
+	v := Value{Type: h.typ, raw: &valueRaw{head: h}}
+	switch h.typ {
+	case Zero:
+	case Int8:
+		b, err := d.readByte()
+		if err != nil {
+			return v, err
+		}
+		v.Int = int64(int8(b))
+	case Int16:
+		n, err := d.readUint16()
+		v.Int = int64(int16(n))
+		return v, err
+	case Int32:
+		n, err := d.readUint32()
+		v.Int = int64(int32(n))
+		return v, err
+	case Int64:
+		n, err := d.readUint64()
+		v.Int = int64(n)
+		return v, err
+	case Float32:
+		n, err := d.readUint32()
+		v.Float = float64(math.Float32frombits(n))
+		return v, err
+	case Float64:
+		n, err := d.readUint64()
+		v.Float = math.Float64frombits(n)
+		return v, err
+	case String1, String4:
+		var n int
+		if h.typ == String1 {
+			b, err := d.readByte()
+			if err != nil {
+				return v, err
+			}
+			n = int(b)
+		} else {
+			l, err := d.readUint32()
+			if err != nil {
+				return v, err
+			}
+			n = int(int32(l))
+		}
+		if err := d.checkStringLength(n); err != nil {
+			return v, err
+		}
+		v.Bytes, err = d.readNBytes(n)
+		return v, err
+	case SimpleList:
+		if v.raw.elem, err = d.readHead(); err != nil {
+			return v, err
+		}
+		if v.raw.elem.typ != Int8 {
+			return v, fmt.Errorf("read 'SimpleList' with invalid element type: %v", v.raw.elem.typ)
+		}
+		n, err := d.readAnySize(v.raw)
+		if err != nil {
+			return v, err
+		}
+		if err = d.checkStringLength(n); err != nil {
+			return v, err
+		}
+		v.Bytes, err = d.readNBytes(n)
+		return v, err
+	case List, Map:
+		if err := d.enter(); err != nil {
+			return v, err
+		}
+		defer d.leave()
+		n, err := d.readAnySize(v.raw)
+		if err != nil {
+			return v, err
+		}
+		if h.typ == List {
+			v.List = make([]Value, 0, preallocSize(n))
+			for i := 0; i < n; i++ {
+				elem, err := d.readAnyValue()
+				if err != nil {
+					return v, err
+				}
+				v.List = append(v.List, elem)
+			}
+			return v, nil
+		}
+		v.Map = make([]MapEntry, 0, preallocSize(n))
+		for i := 0; i < n; i++ {
+			key, err := d.readAnyValue()
+			if err != nil {
+				return v, err
+			}
+			val, err := d.readAnyValue()
+			if err != nil {
+				return v, err
+			}
+			v.Map = append(v.Map, MapEntry{Key: key, Value: val})
+		}
+	case StructBegin:
+		if err := d.enter(); err != nil {
+			return v, err
+		}
+		defer d.leave()
+		for {
+			_, headType, _, err := d.peekTypeTag()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return v, err
+			}
+			if headType == StructEnd {
+				v.raw.end, err = d.readHead()
+				return v, err
+			}
+			f, err := d.readAnyValue()
+			if err != nil {
+				return v, err
+			}
+			v.Fields = append(v.Fields, Field{Tag: f.raw.head.tag, Value: f})
+		}
+	default:
+		return v, fmt.Errorf("read invalid type: %v", h.typ)
+	}
+	return v, nil
+}
+
+// readAnySize 读取 List、Map、SimpleList 的长度并检查限制
+func (d *Decoder) readAnySize(raw *valueRaw) (int, error) {
+	size, err := d.readAnyValue()
+	if err != nil {
+		return 0, err
+	}
+	switch size.Type {
+	case Zero, Int8, Int16, Int32:
+	default:
+		return 0, fmt.Errorf("read size with invalid type: %v", size.Type)
+	}
+	if size.Int < 0 {
+		return 0, fmt.Errorf("invalid container size: %d", size.Int)
+	}
+	if max := d.state.opts.MaxContainerElements; max > 0 && size.Int > int64(max) && raw.head.typ != SimpleList {
+		return 0, &LimitError{Limit: "MaxContainerElements", Max: int64(max), Value: size.Int}
+	}
+	raw.size = &size
+	return int(size.Int), nil
+}
+
+// EncodeAny 编码 DecodeAny 得到的字段，未修改的部分与原始数据逐字节相同
+func EncodeAny(w io.Writer, fields []Field) error {
+	b, err := AppendAny(nil, fields)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// AppendAny 将字段编码后追加到 dst
+func AppendAny(dst []byte, fields []Field) ([]byte, error) {
+	var err error
+	for i := range fields {
+		if dst, err = appendValue(dst, fields[i].Tag, &fields[i].Value); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+func appendHead(dst []byte, h fieldHead) []byte {
+	if h.long && h.tag < 15 {
+		return append(dst, byte(h.typ+240), byte(h.tag))
+	}
+	return AppendHead(dst, h.tag, h.typ)
+}
+
+// elemTag 元素的 tag，解码得到的值保留原来的 tag
+func elemTag(v *Value, tag JceTag) JceTag {
+	if v.raw != nil {
+		return v.raw.head.tag
+	}
+	return tag
+}
+
+func appendValue(dst []byte, tag JceTag, v *Value) ([]byte, error) {
+	h := fieldHead{tag: tag, typ: v.Type}
+	if v.raw != nil {
+		h.long = v.raw.head.long
+	}
+	dst = appendHead(dst, h)
+
+	var err error
+	switch v.Type {
+	case Zero:
+		if v.Int != 0 {
+			return dst, fmt.Errorf("value %d out of range for 'Zero', tag: %d", v.Int, tag)
+		}
+	case Int8:
+		if v.Int < math.MinInt8 || v.Int > math.MaxInt8 {
+			return dst, fmt.Errorf("value %d out of range for 'Int8', tag: %d", v.Int, tag)
+		}
+		dst = append(dst, byte(v.Int))
+	case Int16:
+		if v.Int < math.MinInt16 || v.Int > math.MaxInt16 {
+			return dst, fmt.Errorf("value %d out of range for 'Int16', tag: %d", v.Int, tag)
+		}
+		dst = binary.BigEndian.AppendUint16(dst, uint16(v.Int))
+	case Int32:
+		if v.Int < math.MinInt32 || v.Int > math.MaxInt32 {
+			return dst, fmt.Errorf("value %d out of range for 'Int32', tag: %d", v.Int, tag)
+		}
+		dst = binary.BigEndian.AppendUint32(dst, uint32(v.Int))
+	case Int64:
+		dst = binary.BigEndian.AppendUint64(dst, uint64(v.Int))
+	case Float32:
+		dst = binary.BigEndian.AppendUint32(dst, math.Float32bits(float32(v.Float)))
+	case Float64:
+		dst = binary.BigEndian.AppendUint64(dst, math.Float64bits(v.Float))
+	case String1:
+		if len(v.Bytes) > 255 {
+			return dst, fmt.Errorf("string length %d out of range for 'String1', tag: %d", len(v.Bytes), tag)
+		}
+		dst = append(dst, byte(len(v.Bytes)))
+		dst = append(dst, v.Bytes...)
+	case String4:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(v.Bytes)))
+		dst = append(dst, v.Bytes...)
+	case SimpleList:
+		elem := fieldHead{tag: 0, typ: Int8}
+		if v.raw != nil {
+			elem = v.raw.elem
+		}
+		dst = appendHead(dst, elem)
+		if dst, err = appendSize(dst, v, len(v.Bytes)); err != nil {
+			return dst, err
+		}
+		dst = append(dst, v.Bytes...)
+	case List:
+		if dst, err = appendSize(dst, v, len(v.List)); err != nil {
+			return dst, err
+		}
+		for i := range v.List {
+			elem := &v.List[i]
+			if dst, err = appendValue(dst, elemTag(elem, 0), elem); err != nil {
+				return dst, err
+			}
+		}
+	case Map:
+		if dst, err = appendSize(dst, v, len(v.Map)); err != nil {
+			return dst, err
+		}
+		for i := range v.Map {
+			e := &v.Map[i]
+			if dst, err = appendValue(dst, elemTag(&e.Key, 0), &e.Key); err != nil {
+				return dst, err
+			}
+			if dst, err = appendValue(dst, elemTag(&e.Value, 1), &e.Value); err != nil {
+				return dst, err
+			}
+		}
+	case StructBegin:
+		for i := range v.Fields {
+			if dst, err = appendValue(dst, v.Fields[i].Tag, &v.Fields[i].Value); err != nil {
+				return dst, err
+			}
+		}
+		end := fieldHead{tag: 0, typ: StructEnd}
+		if v.raw != nil {
+			end = v.raw.end
+		}
+		dst = appendHead(dst, end)
+	default:
+		return dst, fmt.Errorf("invalid type: %v to encode, tag: %d", v.Type, tag)
+	}
+	return dst, nil
+}
+
+// appendSize 写入长度，长度未改变时使用原来的编码
+func appendSize(dst []byte, v *Value, n int) ([]byte, error) {
+	if v.raw != nil && v.raw.size != nil && v.raw.size.Int == int64(n) {
+		return appendValue(dst, elemTag(v.raw.size, 0), v.raw.size)
+	}
+	return AppendInt32(dst, int32(n), 0), nil
+}
@@ -0,0 +1,162 @@
+package gojce
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ToJSON 将 JCE 数据转换为 JSON。v 为 nil 时不依赖 Go 类型，以 tag 作为对象的 key；
+// 否则先解码到 v（带 `tag:"N"` 标注的结构体指针或 Message），字段名取自 json 标注
+func ToJSON(data []byte, v any) ([]byte, error) {
+	if v == nil {
+		fields, err := NewBytesDecoder(data).DecodeAny()
+		if err != nil {
+			return nil, err
+		}
+		return AppendFieldsJSON(nil, fields)
+	}
+	if err := UnmarshalStruct(data, v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// FromJSON 将 JSON 解析到 v 后编码为 JCE，未出现的字段取 ResetDefault 的默认值
+func FromJSON(js []byte, v any) ([]byte, error) {
+	if err := ResetDefault(v); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(js, v); err != nil {
+		return nil, err
+	}
+	return MarshalStruct(v)
+}
+
+// AppendFieldsJSON 将 DecodeAny 得到的字段以 JSON 对象追加到 dst，key 为 tag
+//
+// 整数和浮点数转为数字，NaN 和 Inf 转为字符串；string 转为字符串，vector<byte> 转为 base64；
+// map 的 key 都是整数或字符串时转为对象，否则转为 {"key":..., "value":...} 数组
+func AppendFieldsJSON(dst []byte, fields []Field) ([]byte, error) {
+	dst = append(dst, '{')
+	var err error
+	for i := range fields {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, '"')
+		dst = strconv.AppendUint(dst, uint64(fields[i].Tag), 10)
+		dst = append(dst, '"', ':')
+		if dst, err = AppendValueJSON(dst, &fields[i].Value); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// AppendValueJSON 将单个值转为 JSON 追加到 dst，规则同 AppendFieldsJSON
+func AppendValueJSON(dst []byte, v *Value) ([]byte, error) {
+	var err error
+	switch v.Type {
+	case Zero, Int8, Int16, Int32, Int64:
+		dst = strconv.AppendInt(dst, v.Int, 10)
+	case Float32, Float64:
+		dst = appendFloatJSON(dst, v.Float, v.Type == Float32)
+	case String1, String4:
+		dst = appendStringJSON(dst, string(v.Bytes))
+	case SimpleList:
+		dst = append(dst, '"')
+		dst = append(dst, base64.StdEncoding.EncodeToString(v.Bytes)...)
+		dst = append(dst, '"')
+	case List:
+		dst = append(dst, '[')
+		for i := range v.List {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = AppendValueJSON(dst, &v.List[i]); err != nil {
+				return dst, err
+			}
+		}
+		dst = append(dst, ']')
+	case Map:
+		if !scalarKeys(v.Map) {
+			dst = append(dst, '[')
+			for i := range v.Map {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				dst = append(dst, `{"key":`...)
+				if dst, err = AppendValueJSON(dst, &v.Map[i].Key); err != nil {
+					return dst, err
+				}
+				dst = append(dst, `,"value":`...)
+				if dst, err = AppendValueJSON(dst, &v.Map[i].Value); err != nil {
+					return dst, err
+				}
+				dst = append(dst, '}')
+			}
+			return append(dst, ']'), nil
+		}
+		dst = append(dst, '{')
+		for i := range v.Map {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			key := &v.Map[i].Key
+			if key.Type == String1 || key.Type == String4 {
+				dst = appendStringJSON(dst, string(key.Bytes))
+			} else {
+				dst = append(dst, '"')
+				dst = strconv.AppendInt(dst, key.Int, 10)
+				dst = append(dst, '"')
+			}
+			dst = append(dst, ':')
+			if dst, err = AppendValueJSON(dst, &v.Map[i].Value); err != nil {
+				return dst, err
+			}
+		}
+		dst = append(dst, '}')
+	case StructBegin:
+		return AppendFieldsJSON(dst, v.Fields)
+	default:
+		return dst, fmt.Errorf("invalid type: %v to convert to json", v.Type)
+	}
+	return dst, nil
+}
+
+// appendFloatJSON 追加浮点数，JSON 不支持的 NaN、Inf 写为字符串 "NaN"、"Infinity"、"-Infinity"
+func appendFloatJSON(dst []byte, f float64, single bool) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(dst, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(dst, `"-Infinity"`...)
+	}
+	bits := 64
+	if single {
+		bits = 32
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits)
+}
+
+func appendStringJSON(dst []byte, s string) []byte {
+	b, _ := json.Marshal(s)
+	return append(dst, b...)
+}
+
+// scalarKeys map 的 key 是否都是整数或字符串，可以作为 JSON 对象的 key
+func scalarKeys(entries []MapEntry) bool {
+	for i := range entries {
+		switch entries[i].Key.Type {
+		case Zero, Int8, Int16, Int32, Int64, String1, String4:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package gojce
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestToJSONSchemaless(t *testing.T) {
+	val := taggedPacket{
+		ID:      1000,
+		Title:   "json",
+		Payload: []byte{1, 2, 3},
+		Items:   []taggedInner{{Name: "a", Score: 0.5}},
+		Attrs:   map[string]taggedInner{"k": {Name: "v"}},
+		Comment: "\"quoted\"",
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := ToJSON(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"1":1000,"2":"json","3":"AQID","4":[{"0":"a","1":0.5}],"5":{"k":{"0":"v","1":0}},"6":{"0":"","1":0},"20":"\"quoted\""}`
+	if string(js) != want {
+		t.Fatalf("%s != %s", js, want)
+	}
+
+	// key 不是整数或字符串的 map，以及 JSON 不支持的浮点数
+	fields := []Field{
+		{Tag: 0, Value: Value{Type: Map, Map: []MapEntry{{
+			Key:   Value{Type: StructBegin, Fields: []Field{{Tag: 0, Value: Value{Type: Int8, Int: 1}}}},
+			Value: Value{Type: Float64, Float: math.Inf(-1)},
+		}}}},
+		{Tag: 1, Value: Value{Type: Float32, Float: math.NaN()}},
+	}
+	data, err = AppendAny(nil, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js, err = ToJSON(data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"0":[{"key":{"0":1},"value":"-Infinity"}],"1":"NaN"}`; string(js) != want {
+		t.Fatalf("%s != %s", js, want)
+	}
+}
+
+func TestJSONTyped(t *testing.T) {
+	val := taggedPacket{
+		ID:      7,
+		Title:   "typed",
+		Payload: []byte("p"),
+		Items:   []taggedInner{{Name: "a", Score: 1}},
+		Attrs:   map[string]taggedInner{},
+		Inner:   taggedInner{Name: "inner"},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := ToJSON(data, new(taggedPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FromJSON(js, new(taggedPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("%x != %x", out, data)
+	}
+
+	// Message 先取默认值再解析 JSON
+	p := newBenchPacket()
+	data, err = Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js, err = ToJSON(data, new(RequestPacket)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = FromJSON(js, new(RequestPacket)); err != nil {
+		t.Fatal(err)
+	}
+	var p2 RequestPacket
+	if err := Unmarshal(data, &p2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*p, p2) {
+		t.Fatalf("%+v != %+v", *p, p2)
+	}
+	if _, err := FromJSON([]byte(`{"ID":"x"}`), new(taggedPacket)); err == nil {
+		t.Fatal("expect error")
+	}
+}
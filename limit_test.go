@@ -0,0 +1,129 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	// tag 0 String4，声明长度 0x7fffffff
+	hugeString := []byte{0x07, 0x7f, 0xff, 0xff, 0xff, 'a'}
+	// tag 0 List，声明 0x7fffffff 个元素
+	hugeList := []byte{0x09, 0x02, 0x7f, 0xff, 0xff, 0xff, 0x0c}
+	// tag 0 SimpleList，声明 0x7fffffff 个字节
+	hugeBytes := []byte{0x0d, 0x00, 0x02, 0x7f, 0xff, 0xff, 0xff}
+
+	var s string
+	var l []int32
+	var b []byte
+	// 不设置限制时不能按声明的长度分配内存
+	if err := NewDecoder(bytes.NewReader(hugeString)).ReadString(&s, 0, true); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("string: %v", err)
+	}
+	if err := NewDecoder(bytes.NewReader(hugeList)).Decode(&l, 0, true); err == nil {
+		t.Fatal("list: expect error")
+	}
+	if err := NewDecoder(bytes.NewReader(hugeBytes)).ReadBytes(&b, 0, true); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("bytes: %v", err)
+	}
+
+	opts := DecoderOptions{MaxStringLength: 1024, MaxContainerElements: 1024}
+	cases := []struct {
+		name  string
+		data  []byte
+		v     any
+		limit string
+	}{
+		{"string", hugeString, &s, "MaxStringLength"},
+		{"list", hugeList, &l, "MaxContainerElements"},
+		{"strings", hugeList, new([]string), "MaxContainerElements"},
+		{"map", []byte{0x08, 0x02, 0x7f, 0xff, 0xff, 0xff}, new(map[string]string), "MaxContainerElements"},
+		{"bytes", hugeBytes, &b, "MaxStringLength"},
+		// 跳过未知字段时同样检查限制
+		{"skip", append([]byte{0x0c}, hugeList[:6]...), new(taggedInner), "MaxContainerElements"},
+	}
+	for _, c := range cases {
+		d := NewDecoderWithOptions(bytes.NewReader(c.data), opts)
+		var err error
+		if c.name == "skip" {
+			err = d.Decode(c.v, 2, false)
+		} else {
+			err = d.Decode(c.v, 0, true)
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != c.limit || !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	node := taggedNode{Value: 1, Children: []taggedNode{{Value: 2, Children: []taggedNode{{Value: 3}}}}}
+	buf := new(bytes.Buffer)
+	e := NewEncoder(buf)
+	if err := e.Encode(node, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 结构体和 vector 各算一层，空的 Children 也会编码为 vector，共 6 层
+	var v taggedNode
+	if err := NewDecoderWithOptions(bytes.NewReader(buf.Bytes()), DecoderOptions{MaxDepth: 6}).Decode(&v, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	err := NewDecoderWithOptions(bytes.NewReader(buf.Bytes()), DecoderOptions{MaxDepth: 5}).Decode(&v, 0, true)
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxDepth" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 跳过的字段同样受嵌套层数限制
+	var inner taggedInner
+	err = NewDecoderWithOptions(bytes.NewReader(buf.Bytes()), DecoderOptions{MaxDepth: 5}).Decode(&inner, 1, false)
+	if !errors.As(err, &le) || le.Limit != "MaxDepth" {
+		t.Fatalf("skip: unexpected error %v", err)
+	}
+}
+
+func TestDefaultMaxDepth(t *testing.T) {
+	// 深度嵌套的 vector，无类型解码、转 JSON 和跳过字段都不会栈溢出
+	var data []byte
+	for i := 0; i < 100000; i++ {
+		data = AppendInt8(AppendHead(data, 0, List), 1, 0)
+	}
+	data = AppendInt8(AppendHead(data, 0, List), 0, 0)
+	var le *LimitError
+	if _, err := NewBytesDecoder(data).DecodeAny(); !errors.As(err, &le) || le.Max != DefaultMaxDepth {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := ToJSON(data, nil); !errors.As(err, &le) {
+		t.Fatalf("json: unexpected error %v", err)
+	}
+	var inner taggedInner
+	if err := NewBytesDecoder(data).Decode(&inner, 1, false); !errors.As(err, &le) {
+		t.Fatalf("skip: unexpected error %v", err)
+	}
+}
+
+func TestUnmarshalWithOptions(t *testing.T) {
+	p := newBenchPacket()
+	data, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p2 RequestPacket
+	if err := UnmarshalWithOptions(data, &p2, DecoderOptions{MaxTotalBytes: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalWithOptions(data, &p2, DecoderOptions{MaxTotalBytes: int64(len(data) - 1)})
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxTotalBytes" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = UnmarshalWithOptions(data, &p2, DecoderOptions{MaxStringLength: 16})
+	if !errors.As(err, &le) || le.Limit != "MaxStringLength" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package gojce
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hookUUID 以十六进制字符串编码，值接收者编码、指针接收者解码
+type hookUUID [4]byte
+
+func (u hookUUID) MarshalJCE(e *Encoder, tag JceTag) error {
+	return e.WriteString(hex.EncodeToString(u[:]), tag)
+}
+
+func (u *hookUUID) UnmarshalJCE(d *Decoder, tag JceTag, required bool) error {
+	var s string
+	if err := d.ReadString(&s, tag, required); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(u) {
+		return fmt.Errorf("invalid uuid %q", s)
+	}
+	copy(u[:], b)
+	return nil
+}
+
+// hookDecimal 没有 tag 标注的结构体，编码为 "unscaled/scale" 字符串
+type hookDecimal struct {
+	unscaled int64
+	scale    int32
+}
+
+func (m *hookDecimal) MarshalJCE(e *Encoder, tag JceTag) error {
+	return e.WriteString(fmt.Sprintf("%d/%d", m.unscaled, m.scale), tag)
+}
+
+func (m *hookDecimal) UnmarshalJCE(d *Decoder, tag JceTag, required bool) error {
+	var s string
+	if err := d.ReadString(&s, tag, required); err != nil {
+		return err
+	}
+	a, b, ok := strings.Cut(s, "/")
+	if !ok {
+		return fmt.Errorf("invalid decimal %q", s)
+	}
+	unscaled, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return err
+	}
+	scale, err := strconv.ParseInt(b, 10, 32)
+	if err != nil {
+		return err
+	}
+	m.unscaled, m.scale = unscaled, int32(scale)
+	return nil
+}
+
+type hookPacket struct {
+	ID      hookUUID               `tag:"0"`
+	Price   hookDecimal            `tag:"1"`
+	Prices  []hookDecimal          `tag:"2"`
+	At      time.Time              `tag:"3"`
+	Timeout time.Duration          `tag:"4"`
+	ByID    map[hookUUID]time.Time `tag:"5"`
+}
+
+func TestMarshalerHooks(t *testing.T) {
+	at := time.UnixMilli(1700000000123)
+	val := hookPacket{
+		ID:      hookUUID{1, 2, 3, 0xff},
+		Price:   hookDecimal{1234, 2},
+		Prices:  []hookDecimal{{1, 0}, {-5, 3}},
+		At:      at,
+		Timeout: 1500 * time.Millisecond,
+		ByID:    map[hookUUID]time.Time{{9}: at},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	want = AppendString(want, "010203ff", 0)
+	want = AppendString(want, "1234/2", 1)
+	want = AppendHead(want, 2, List)
+	want = AppendInt32(want, 2, 0)
+	want = AppendString(want, "1/0", 0)
+	want = AppendString(want, "-5/3", 0)
+	want = AppendInt64(want, 1700000000123, 3)
+	want = AppendInt64(want, 1500, 4)
+	want = AppendHead(want, 5, Map)
+	want = AppendInt32(want, 1, 0)
+	want = AppendString(want, "09000000", 0)
+	want = AppendInt64(want, 1700000000123, 1)
+	if !bytes.Equal(data, want) {
+		t.Fatalf("%x != %x", data, want)
+	}
+
+	var got hookPacket
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, val) {
+		t.Fatalf("%+v != %+v", got, val)
+	}
+
+	// 零值的时间编码为 0
+	data, err = MarshalStruct(&hookPacket{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.At = at
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.At.IsZero() || got.Timeout != 0 {
+		t.Fatalf("%+v", got)
+	}
+
+	// 自定义解码的错误路径不重复 tag
+	for _, c := range []struct {
+		data []byte
+		path string
+	}{
+		{AppendString(nil, "bad", 1), "hookPacket.1"},
+		{AppendString(AppendInt32(AppendHead(nil, 2, List), 1, 0), "x", 0), "hookPacket.2[0]"},
+		{AppendInt32(nil, 1, 0), "hookPacket.0"},
+	} {
+		err := UnmarshalStruct(c.data, new(hookPacket))
+		var de *DecodeError
+		if !errors.As(err, &de) || de.Path != c.path {
+			t.Fatalf("%s: unexpected error %v", c.path, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package gojce
 import (
 	"bytes"
 	"io"
+	"reflect"
 )
 
 // Message gojce消息体接口， 类似protobuf
@@ -16,15 +17,44 @@ type Message interface {
 
 // Marshal gojce 打包函数 与标准包 json xml proto 保持一致
 func Marshal(m Message) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.Encode(buf); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return MarshalAppend(nil, m)
 }
 
 // Unmarshal gojce 解包
 func Unmarshal(data []byte, m Message) error {
 	buf := bytes.NewBuffer(data)
-	return m.Decode(buf)
+	return rootError(m.Decode(buf), m)
+}
+
+// MarshalStruct 打包带 `tag:"N"` 标注的结构体，实现了 Struct 接口的类型直接调用其 Encode
+func MarshalStruct(v any) ([]byte, error) {
+	if s, ok := v.(Struct); ok {
+		return MarshalAppend(nil, s)
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	e := NewBufferEncoder(nil)
+	if err := e.encodeStructFields(rv); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// UnmarshalStruct 解包到带 `tag:"N"` 标注的结构体，v 必须是非空指针
+func UnmarshalStruct(data []byte, v any) error {
+	if s, ok := v.(Struct); ok {
+		return rootError(s.Decode(bytes.NewReader(data)), s)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &UnmarshalError{reflect.TypeOf(v)}
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+	d := NewDecoder(bytes.NewReader(data))
+	return rootError(d.decodeStructFields(rv), v)
 }
@@ -0,0 +1,274 @@
+package gojce
+
+import (
+	"io"
+)
+
+// 协议版本，IVersion 取值
+const (
+	JceVersion   int16 = 1
+	TupVersion   int16 = 2
+	TupVersionV3 int16 = 3
+)
+
+// 包类型，CPacketType 取值
+const (
+	NormalPacket byte = 0
+	OnewayPacket byte = 1
+)
+
+// 服务端返回码，ResponsePacket.IRet 取值
+const (
+	ServerSuccess      int32 = 0
+	ServerDecodeErr    int32 = -1
+	ServerEncodeErr    int32 = -2
+	ServerNoFuncErr    int32 = -3
+	ServerNoServantErr int32 = -4
+	ServerQueueTimeout int32 = -6
+	InvokeTimeout      int32 = -7
+	ProxyConnectErr    int32 = -8
+	ServerOverload     int32 = -9
+	ClientDecodeErr    int32 = -12
+	ServerUnknownErr   int32 = -99
+)
+
+// RequestPacket Tars/JCE RPC 请求包，SBuffer 为编码后的调用参数，
+// 字段的 require/optional 与 Tars 的 RequestF.jce 相同
+type RequestPacket struct {
+	IVersion     int16             `tag:"1"  required:"true"`
+	CPacketType  byte              `tag:"2"`
+	IMessageType int32             `tag:"3"`
+	IRequestId   int32             `tag:"4"  required:"true"`
+	SServantName string            `tag:"5"  required:"true"`
+	SFuncName    string            `tag:"6"  required:"true"`
+	SBuffer      []byte            `tag:"7"  required:"true"`
+	ITimeout     int32             `tag:"8"`
+	Context      map[string]string `tag:"9"`
+	Status       map[string]string `tag:"10"`
+}
+
+func (p *RequestPacket) Encode(w io.Writer) error {
+	var err error
+	encoder := NewEncoder(w)
+	err = encoder.WriteInt16(p.IVersion, 1)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteByte(p.CPacketType, 2)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.IMessageType, 3)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.IRequestId, 4)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteString(p.SServantName, 5)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteString(p.SFuncName, 6)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteBytes(p.SBuffer, 7)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.ITimeout, 8)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteMap(p.Context, 9)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteMap(p.Status, 10)
+	if nil != err {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (p *RequestPacket) Decode(r io.Reader) error {
+	var err error
+	decoder := NewDecoder(r)
+	p.ResetDefault()
+	err = decoder.ReadInt16(&p.IVersion, 1, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadByte(&p.CPacketType, 2, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.IMessageType, 3, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.IRequestId, 4, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadString(&p.SServantName, 5, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadString(&p.SFuncName, 6, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadBytes(&p.SBuffer, 7, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.ITimeout, 8, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadMap(&p.Context, 9, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadMap(&p.Status, 10, false)
+	if nil != err {
+		return err
+	}
+	return err
+}
+
+func (p *RequestPacket) ClassName() string {
+	return "tars.RequestPacket"
+}
+
+// MD5 与 jce2go 相同，为 RequestF.jce 中结构体规范化定义的 md5
+func (p *RequestPacket) MD5() string {
+	return "892292f1ac1fea69ef0d6035aac03a61"
+}
+
+func (p *RequestPacket) ResetDefault() {
+	*p = RequestPacket{}
+}
+
+// ResetDefautlt 同 ResetDefault，实现 Message 接口
+func (p *RequestPacket) ResetDefautlt() {
+	p.ResetDefault()
+}
+
+// ResponsePacket Tars/JCE RPC 响应包，SBuffer 为编码后的返回值和出参
+type ResponsePacket struct {
+	IVersion     int16             `tag:"1"  required:"true"`
+	CPacketType  byte              `tag:"2"`
+	IRequestId   int32             `tag:"3"  required:"true"`
+	IMessageType int32             `tag:"4"`
+	IRet         int32             `tag:"5"`
+	SBuffer      []byte            `tag:"6"  required:"true"`
+	Status       map[string]string `tag:"7"`
+	SResultDesc  string            `tag:"8"`
+	Context      map[string]string `tag:"9"`
+}
+
+func (p *ResponsePacket) Encode(w io.Writer) error {
+	var err error
+	encoder := NewEncoder(w)
+	err = encoder.WriteInt16(p.IVersion, 1)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteByte(p.CPacketType, 2)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.IRequestId, 3)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.IMessageType, 4)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteInt32(p.IRet, 5)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteBytes(p.SBuffer, 6)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteMap(p.Status, 7)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteString(p.SResultDesc, 8)
+	if nil != err {
+		return err
+	}
+	err = encoder.WriteMap(p.Context, 9)
+	if nil != err {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (p *ResponsePacket) Decode(r io.Reader) error {
+	var err error
+	decoder := NewDecoder(r)
+	p.ResetDefault()
+	err = decoder.ReadInt16(&p.IVersion, 1, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadByte(&p.CPacketType, 2, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.IRequestId, 3, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.IMessageType, 4, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadInt32(&p.IRet, 5, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadBytes(&p.SBuffer, 6, true)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadMap(&p.Status, 7, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadString(&p.SResultDesc, 8, false)
+	if nil != err {
+		return err
+	}
+	err = decoder.ReadMap(&p.Context, 9, false)
+	if nil != err {
+		return err
+	}
+	return err
+}
+
+func (p *ResponsePacket) ClassName() string {
+	return "tars.ResponsePacket"
+}
+
+func (p *ResponsePacket) MD5() string {
+	return "afd2ea97640df93352a2d9063dfae495"
+}
+
+func (p *ResponsePacket) ResetDefault() {
+	*p = ResponsePacket{}
+}
+
+// ResetDefautlt 同 ResetDefault，实现 Message 接口
+func (p *ResponsePacket) ResetDefautlt() {
+	p.ResetDefault()
+}
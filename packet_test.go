@@ -0,0 +1,134 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFrameCodec(t *testing.T) {
+	req := &RequestPacket{
+		IVersion:     JceVersion,
+		CPacketType:  NormalPacket,
+		IRequestId:   7,
+		SServantName: "Test.HelloServer.HelloObj",
+		SFuncName:    "sayHello",
+		SBuffer:      []byte("args"),
+		ITimeout:     3000,
+		Context:      map[string]string{"k": "v"},
+		Status:       map[string]string{},
+	}
+	rsp := &ResponsePacket{
+		IVersion:    JceVersion,
+		IRequestId:  7,
+		IRet:        ServerNoFuncErr,
+		SBuffer:     []byte{},
+		Status:      map[string]string{},
+		SResultDesc: "no func",
+		Context:     map[string]string{},
+	}
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, 0)
+	if err := fw.WriteMessage(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteMessage(rsp); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteFrame([]byte("raw")); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := NewFrameReader(&buf, 0)
+	var req2 RequestPacket
+	if err := fr.ReadRequest(&req2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, &req2) {
+		t.Fatalf("%+v != %+v", req, req2)
+	}
+	var rsp2 ResponsePacket
+	if err := fr.ReadResponse(&rsp2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rsp, &rsp2) {
+		t.Fatalf("%+v != %+v", rsp, rsp2)
+	}
+	body, err := fr.ReadFrame()
+	if err != nil || string(body) != "raw" {
+		t.Fatal(body, err)
+	}
+	if _, err = fr.ReadFrame(); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestMinimalPackets(t *testing.T) {
+	// Tars 对端可以省略 optional 字段，只包含 require 字段的包能解码
+	var data []byte
+	data = AppendInt16(data, JceVersion, 1)
+	data = AppendInt32(data, 7, 4)
+	data = AppendString(data, "Test.HelloServer.HelloObj", 5)
+	data = AppendString(data, "sayHello", 6)
+	data = AppendBytes(data, []byte("args"), 7)
+	want := RequestPacket{
+		IVersion:     JceVersion,
+		IRequestId:   7,
+		SServantName: "Test.HelloServer.HelloObj",
+		SFuncName:    "sayHello",
+		SBuffer:      []byte("args"),
+	}
+	req := RequestPacket{CPacketType: OnewayPacket, ITimeout: 1, Context: map[string]string{"k": "v"}}
+	if err := req.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Fatalf("%+v != %+v", req, want)
+	}
+	var req2 RequestPacket
+	if err := UnmarshalStruct(data, &req2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req2, want) {
+		t.Fatalf("%+v != %+v", req2, want)
+	}
+
+	data = AppendInt32(AppendInt16(nil, JceVersion, 1), 7, 3)
+	data = AppendBytes(data, []byte{}, 6)
+	rsp := ResponsePacket{IRet: ServerDecodeErr, SResultDesc: "stale"}
+	if err := rsp.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.IRequestId != 7 || rsp.IRet != ServerSuccess || rsp.SResultDesc != "" || rsp.Status != nil {
+		t.Fatalf("%+v", rsp)
+	}
+
+	// 缺少 require 字段时报错
+	data = AppendInt32(AppendInt16(nil, JceVersion, 1), 7, 3)
+	if err := rsp.Decode(bytes.NewReader(data)); !errors.Is(err, ErrJceDecodeRequireNotExist) {
+		t.Fatal(err)
+	}
+}
+
+func TestFrameLimits(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, 8)
+	if err := fw.WriteFrame([]byte("12345")); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatal(err)
+	}
+
+	fr := NewFrameReader(bytes.NewReader([]byte{0, 0, 0x10, 0}), 1024)
+	if _, err := fr.ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatal(err)
+	}
+	fr = NewFrameReader(bytes.NewReader([]byte{0, 0, 0, 3}), 1024)
+	if _, err := fr.ReadFrame(); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatal(err)
+	}
+	fr = NewFrameReader(bytes.NewReader([]byte{0, 0, 0, 8, 1}), 1024)
+	if _, err := fr.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package gojce
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type pointerPacket struct {
+	ID      int32                   `tag:"0" required:"true"`
+	Count   *int32                  `tag:"1"`
+	Name    *string                 `tag:"2"`
+	Inner   *taggedInner            `tag:"3"`
+	Request *RequestPacket          `tag:"4"`
+	Items   []*taggedInner          `tag:"5"`
+	Attrs   map[string]*taggedInner `tag:"6"`
+}
+
+func TestPointerFields(t *testing.T) {
+	zero, empty := int32(0), ""
+	val := pointerPacket{
+		ID:      1,
+		Count:   &zero,
+		Name:    &empty,
+		Inner:   &taggedInner{Name: "in"},
+		Request: &RequestPacket{SFuncName: "f", SBuffer: []byte{}, Context: map[string]string{}, Status: map[string]string{}},
+		Items:   []*taggedInner{{Name: "a"}},
+		Attrs:   map[string]*taggedInner{"k": {Name: "v", Score: 1}},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pointerPacket
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, val) {
+		t.Fatalf("%+v != %+v", got, val)
+	}
+
+	// nil 不编码，解码时保持 nil，与零值区分
+	data, err = MarshalStruct(&pointerPacket{ID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AppendHead(AppendInt32(nil, 2, 0), 5, List)
+	want = AppendInt32(want, 0, 0)
+	want = AppendInt32(AppendHead(want, 6, Map), 0, 0)
+	if !bytes.Equal(data, want) {
+		t.Fatalf("%x != %x", data, want)
+	}
+	if err := UnmarshalStruct(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 2 || got.Count != nil || got.Name != nil || got.Inner != nil || got.Request != nil {
+		t.Fatalf("%+v", got)
+	}
+
+	// 必填字段和容器中的 nil 仍然报错
+	if _, err := MarshalStruct(&struct {
+		ID *int32 `tag:"0" required:"true"`
+	}{}); err == nil || !strings.Contains(err.Error(), "nil pointer") {
+		t.Fatal(err)
+	}
+	if _, err := MarshalStruct(&pointerPacket{Items: []*taggedInner{nil}}); err == nil {
+		t.Fatal("expect nil pointer error")
+	}
+
+	// Decode 到 nil 指针时只在字段存在时分配
+	var p *int32
+	if err := NewBytesDecoder(AppendInt32(nil, 5, 1)).Decode(&p, 0, false); err != nil || p != nil {
+		t.Fatal(err, p)
+	}
+	if err := NewBytesDecoder(AppendInt32(nil, 5, 1)).Decode(&p, 1, false); err != nil || p == nil || *p != 5 {
+		t.Fatal(err, p)
+	}
+}
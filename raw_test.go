@@ -0,0 +1,131 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rawPacket 与 taggedPacket 的编码兼容，只解析 ID 和 Title
+type rawPacket struct {
+	ID      int32      `tag:"1" required:"true"`
+	Title   string     `tag:"2" required:"true"`
+	Payload RawMessage `tag:"3"`
+	Items   RawMessage `tag:"4"`
+	Attrs   RawMessage `tag:"5"`
+	Inner   RawMessage `tag:"6"`
+	Comment RawMessage `tag:"20"`
+}
+
+func TestRawMessage(t *testing.T) {
+	val := taggedPacket{
+		ID:      1,
+		Title:   "raw",
+		Payload: []byte{1, 2, 3},
+		Items:   []taggedInner{{Name: "a", Score: 1.5}, {Name: "b"}},
+		Attrs:   map[string]taggedInner{"k": {Name: "v"}},
+		Inner:   taggedInner{Name: "inner", Score: 2},
+		Comment: "tail",
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stream rawPacket
+	if err := UnmarshalStruct(data, &stream); err != nil {
+		t.Fatal(err)
+	}
+	e := NewBufferEncoder(nil)
+	if err := e.Encode(&val, 0); err != nil {
+		t.Fatal(err)
+	}
+	var raw rawPacket
+	if err := NewBytesDecoder(e.Bytes()).Decode(&raw, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(raw, stream) {
+		t.Fatalf("%+v != %+v", raw, stream)
+	}
+	if raw.ID != 1 || raw.Title != "raw" || raw.Items.Type() != List || raw.Inner.Type() != StructBegin || raw.Comment.Type() != String1 {
+		t.Fatalf("%+v", raw)
+	}
+	got, err := MarshalStruct(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%x != %x", got, data)
+	}
+
+	// 按原来的类型解码
+	var items []taggedInner
+	if err := raw.Items.Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	var attrs map[string]taggedInner
+	if err := raw.Attrs.Decode(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	var inner taggedInner
+	if err := raw.Inner.Decode(&inner); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, val.Items) || !reflect.DeepEqual(attrs, val.Attrs) || inner != val.Inner {
+		t.Fatal(items, attrs, inner)
+	}
+	var req RequestPacket
+	if err := raw.Inner.Decode(&req); !errors.Is(err, ErrJceDecodeTypeMismatch) {
+		t.Fatal(err)
+	}
+
+	// MarshalRaw 构造的字段与直接编码相同
+	raw.Inner, err = MarshalRaw(&val.Inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = MarshalStruct(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%x != %x", got, data)
+	}
+
+	// nil 不写入
+	got, err = MarshalStruct(&rawPacket{ID: 1, Title: "raw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AppendString(AppendInt32(nil, 1, 1), "raw", 2)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%x != %x", got, want)
+	}
+
+	// 必填字段和 vector、map 的元素为空时报错
+	for name, v := range map[string]any{
+		"required": &struct {
+			A RawMessage `tag:"0" required:"true"`
+		}{},
+		"list": &struct {
+			A []RawMessage `tag:"0"`
+			B int32        `tag:"1"`
+		}{A: []RawMessage{nil}, B: 5},
+		"map": &struct {
+			A map[string]RawMessage `tag:"0"`
+		}{A: map[string]RawMessage{"k": {}}},
+	} {
+		if _, err := MarshalStruct(v); err == nil || !strings.Contains(err.Error(), "empty RawMessage") {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+	if err := NewBufferEncoder(nil).WriteRaw(nil, 1); err == nil {
+		t.Fatal("expect empty RawMessage error")
+	}
+
+	raw.Inner = RawMessage{0x1a, 0x0b}
+	if _, err := MarshalStruct(&raw); err == nil {
+		t.Fatal("expect invalid head error")
+	}
+}
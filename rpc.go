@@ -0,0 +1,30 @@
+package gojce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrServantFuncNotFound 服务端找不到请求的方法
+var ErrServantFuncNotFound = errors.New("servant func not found")
+
+// Invoker 客户端调用接口，发送请求包并返回响应中的 SBuffer
+type Invoker interface {
+	Invoke(ctx context.Context, req *RequestPacket) ([]byte, error)
+}
+
+// Dispatcher 服务端分发接口，按 SFuncName 调用对应方法并返回编码后的结果
+type Dispatcher interface {
+	Dispatch(ctx context.Context, req *RequestPacket) ([]byte, error)
+}
+
+// RPCError 服务端返回的错误，Code 对应 ResponsePacket.IRet
+type RPCError struct {
+	Code int32
+	Desc string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error, ret: %d, desc: %s", e.Code, e.Desc)
+}
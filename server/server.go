@@ -0,0 +1,405 @@
+// Package server 基于 TCP 的 JCE RPC 服务端
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofly/gojce"
+)
+
+// ErrServerClosed Serve 在 Shutdown/Close 之后返回的错误
+var ErrServerClosed = errors.New("server: server closed")
+
+// DispatcherFunc 将普通函数适配为 gojce.Dispatcher
+type DispatcherFunc func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error)
+
+func (f DispatcherFunc) Dispatch(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+	return f(ctx, req)
+}
+
+type requestKey struct{}
+
+// RequestFromContext 返回当前正在处理的请求包
+func RequestFromContext(ctx context.Context) (*gojce.RequestPacket, bool) {
+	req, ok := ctx.Value(requestKey{}).(*gojce.RequestPacket)
+	return req, ok
+}
+
+// Server 按 SServantName 将请求分发给注册的 Dispatcher，零值可直接使用
+type Server struct {
+	// MaxConcurrentRequests 同时处理的请求数上限，<= 0 表示不限制
+	MaxConcurrentRequests int
+	// MaxFrameSize 单个请求帧的最大长度，<= 0 时使用 gojce.DefaultMaxFrameSize
+	MaxFrameSize int
+	// IdleTimeout 连接空闲超时，<= 0 表示不超时
+	IdleTimeout time.Duration
+	// ErrorLog 为 nil 时使用 log 包的默认 Logger
+	ErrorLog *log.Logger
+	// DecoderOptions 解码请求包的限制，面向不受信任的客户端时应设置
+	DecoderOptions gojce.DecoderOptions
+
+	mu         sync.Mutex
+	servants   map[string]gojce.Dispatcher
+	listeners  map[net.Listener]struct{}
+	conns      map[*conn]struct{}
+	sem        chan struct{}
+	active     int
+	inShutdown bool
+	drained    chan struct{}
+}
+
+// Handle 注册 servant，重复注册会覆盖之前的 Dispatcher
+func (srv *Server) Handle(servant string, d gojce.Dispatcher) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.servants == nil {
+		srv.servants = make(map[string]gojce.Dispatcher)
+	}
+	srv.servants[servant] = d
+}
+
+// HandleFunc 注册函数形式的 servant
+func (srv *Server) HandleFunc(servant string, f func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error)) {
+	srv.Handle(servant, DispatcherFunc(f))
+}
+
+func (srv *Server) dispatcher(servant string) gojce.Dispatcher {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.servants[servant]
+}
+
+// ListenAndServe 监听 TCP 地址 addr 并开始服务
+func (srv *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// Serve 在 l 上接受连接，每个连接一个 goroutine，总是返回非 nil 错误
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	if srv.inShutdown {
+		srv.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	if srv.listeners == nil {
+		srv.listeners = make(map[net.Listener]struct{})
+	}
+	srv.listeners[l] = struct{}{}
+	if srv.sem == nil && srv.MaxConcurrentRequests > 0 {
+		srv.sem = make(chan struct{}, srv.MaxConcurrentRequests)
+	}
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.listeners, l)
+		srv.mu.Unlock()
+		l.Close()
+	}()
+
+	var tempDelay time.Duration
+	for {
+		rwc, err := l.Accept()
+		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else if tempDelay *= 2; tempDelay > time.Second {
+					tempDelay = time.Second
+				}
+				srv.logf("server: accept error: %v; retrying in %v", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		c := &conn{srv: srv, rwc: rwc, fw: gojce.NewFrameWriter(rwc, 0)}
+		if !srv.trackConn(c) {
+			rwc.Close()
+			return ErrServerClosed
+		}
+		go c.serve()
+	}
+}
+
+func (srv *Server) shuttingDown() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.inShutdown
+}
+
+func (srv *Server) trackConn(c *conn) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.inShutdown {
+		return false
+	}
+	if srv.conns == nil {
+		srv.conns = make(map[*conn]struct{})
+	}
+	srv.conns[c] = struct{}{}
+	return true
+}
+
+func (srv *Server) untrackConn(c *conn) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	delete(srv.conns, c)
+}
+
+// beginRequest 登记一个处理中的请求，关闭过程中返回 false
+func (srv *Server) beginRequest() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.inShutdown {
+		return false
+	}
+	srv.active++
+	return true
+}
+
+func (srv *Server) endRequest() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.active--
+	if srv.active == 0 && srv.drained != nil {
+		close(srv.drained)
+	}
+}
+
+// Shutdown 停止接受新连接和新请求，等待处理中的请求完成后关闭所有连接；
+// ctx 结束时强制关闭并返回 ctx.Err()
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.mu.Lock()
+	srv.inShutdown = true
+	// 多次调用共用同一个 drained，关闭过程中不再有新请求，active 只会减少
+	if srv.drained == nil {
+		srv.drained = make(chan struct{})
+		if srv.active == 0 {
+			close(srv.drained)
+		}
+	}
+	drained := srv.drained
+	for l := range srv.listeners {
+		l.Close()
+	}
+	// 唤醒阻塞在读取上的连接，不再读取新请求
+	for c := range srv.conns {
+		c.rwc.SetReadDeadline(time.Now())
+	}
+	srv.mu.Unlock()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	srv.closeConns()
+	return err
+}
+
+// Close 立即关闭所有监听和连接，不等待处理中的请求
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	srv.inShutdown = true
+	for l := range srv.listeners {
+		l.Close()
+	}
+	srv.mu.Unlock()
+	srv.closeConns()
+	return nil
+}
+
+func (srv *Server) closeConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for c := range srv.conns {
+		c.rwc.Close()
+	}
+}
+
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// conn 一个客户端连接，请求并发处理，响应写入时加锁
+type conn struct {
+	srv *Server
+	rwc net.Conn
+	wmu sync.Mutex
+	fw  *gojce.FrameWriter
+}
+
+func (c *conn) serve() {
+	srv := c.srv
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		c.rwc.Close()
+		srv.untrackConn(c)
+	}()
+
+	fr := gojce.NewFrameReader(bufio.NewReader(c.rwc), srv.MaxFrameSize)
+	for {
+		if srv.IdleTimeout > 0 && !srv.shuttingDown() {
+			c.rwc.SetReadDeadline(time.Now().Add(srv.IdleTimeout))
+		}
+		body, err := fr.ReadFrame()
+		if err != nil {
+			var ne net.Error
+			if err != io.EOF && !srv.shuttingDown() && !(errors.As(err, &ne) && ne.Timeout()) {
+				srv.logf("server: read request from %v: %v", c.rwc.RemoteAddr(), err)
+			}
+			return
+		}
+		req := new(gojce.RequestPacket)
+		if err = gojce.NewBytesDecoderWithOptions(body, srv.DecoderOptions).DecodeStruct(req); err != nil {
+			srv.logf("server: decode request from %v: %v", c.rwc.RemoteAddr(), err)
+			// 帧的边界仍然完整，能取得 IRequestId 时回复 ServerDecodeErr 并继续读取
+			if !c.replyDecodeError(body, err) {
+				return
+			}
+			continue
+		}
+		if srv.sem != nil {
+			srv.sem <- struct{}{}
+		}
+		if !srv.beginRequest() {
+			if srv.sem != nil {
+				<-srv.sem
+			}
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				if srv.sem != nil {
+					<-srv.sem
+				}
+				srv.endRequest()
+				wg.Done()
+			}()
+			c.handle(req)
+		}()
+	}
+}
+
+func (c *conn) handle(req *gojce.RequestPacket) {
+	ctx := context.WithValue(context.Background(), requestKey{}, req)
+	if req.ITimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.ITimeout)*time.Millisecond)
+		defer cancel()
+	}
+	buf, err := c.dispatch(ctx, req)
+	if req.CPacketType == gojce.OnewayPacket {
+		if err != nil {
+			c.srv.logf("server: oneway call %s.%s: %v", req.SServantName, req.SFuncName, err)
+		}
+		return
+	}
+
+	rsp := &gojce.ResponsePacket{
+		IVersion:     req.IVersion,
+		CPacketType:  gojce.NormalPacket,
+		IRequestId:   req.IRequestId,
+		IMessageType: req.IMessageType,
+		IRet:         gojce.ServerSuccess,
+		SBuffer:      buf,
+		Status:       map[string]string{},
+		Context:      req.Context,
+	}
+	if err != nil {
+		rsp.IRet, rsp.SResultDesc = errorResult(err)
+		rsp.SBuffer = nil
+	}
+	c.wmu.Lock()
+	err = c.fw.WriteMessage(rsp)
+	c.wmu.Unlock()
+	if err != nil {
+		c.srv.logf("server: write response to %v: %v", c.rwc.RemoteAddr(), err)
+		c.rwc.Close()
+	}
+}
+
+// replyDecodeError 解码失败时回复 ServerDecodeErr，取不到 IRequestId 时返回 false
+func (c *conn) replyDecodeError(body []byte, err error) bool {
+	var (
+		version    int16
+		packetType byte
+		id         int32
+	)
+	d := gojce.NewBytesDecoder(body)
+	if d.ReadInt16(&version, 1, true) != nil || d.ReadByte(&packetType, 2, false) != nil || d.ReadInt32(&id, 4, true) != nil {
+		return false
+	}
+	if packetType == gojce.OnewayPacket {
+		return true
+	}
+	rsp := &gojce.ResponsePacket{
+		IVersion:    version,
+		CPacketType: gojce.NormalPacket,
+		IRequestId:  id,
+		IRet:        gojce.ServerDecodeErr,
+		Status:      map[string]string{},
+		SResultDesc: err.Error(),
+	}
+	c.wmu.Lock()
+	err = c.fw.WriteMessage(rsp)
+	c.wmu.Unlock()
+	if err != nil {
+		c.srv.logf("server: write response to %v: %v", c.rwc.RemoteAddr(), err)
+		return false
+	}
+	return true
+}
+
+func (c *conn) dispatch(ctx context.Context, req *gojce.RequestPacket) (buf []byte, err error) {
+	d := c.srv.dispatcher(req.SServantName)
+	if d == nil {
+		return nil, &gojce.RPCError{Code: gojce.ServerNoServantErr, Desc: "servant not found: " + req.SServantName}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.srv.logf("server: panic serving %s.%s: %v", req.SServantName, req.SFuncName, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return d.Dispatch(ctx, req)
+}
+
+// errorResult 将错误转换为 ResponsePacket 的 IRet 和 SResultDesc
+func errorResult(err error) (int32, string) {
+	var re *gojce.RPCError
+	switch {
+	case errors.As(err, &re):
+		return re.Code, re.Desc
+	case errors.Is(err, gojce.ErrServantFuncNotFound):
+		return gojce.ServerNoFuncErr, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return gojce.ServerQueueTimeout, err.Error()
+	}
+	return gojce.ServerUnknownErr, err.Error()
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gofly/gojce"
+)
+
+func startServer(t *testing.T, srv *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.ErrorLog == nil {
+		srv.ErrorLog = log.New(io.Discard, "", 0)
+	}
+	go srv.Serve(l)
+	return l.Addr().String()
+}
+
+type testConn struct {
+	net.Conn
+	fr *gojce.FrameReader
+	fw *gojce.FrameWriter
+}
+
+func dial(t *testing.T, addr string) *testConn {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return &testConn{Conn: c, fr: gojce.NewFrameReader(c, 0), fw: gojce.NewFrameWriter(c, 0)}
+}
+
+func (c *testConn) call(t *testing.T, req *gojce.RequestPacket) *gojce.ResponsePacket {
+	if err := c.fw.WriteMessage(req); err != nil {
+		t.Fatal(err)
+	}
+	rsp := new(gojce.ResponsePacket)
+	if err := c.fr.ReadResponse(rsp); err != nil {
+		t.Fatal(err)
+	}
+	return rsp
+}
+
+func newRequest(id int32, servant, fn string) *gojce.RequestPacket {
+	return &gojce.RequestPacket{
+		IVersion:     gojce.JceVersion,
+		IRequestId:   id,
+		SServantName: servant,
+		SFuncName:    fn,
+		SBuffer:      []byte("ping"),
+		Context:      map[string]string{},
+		Status:       map[string]string{},
+	}
+}
+
+func TestServerDispatch(t *testing.T) {
+	srv := &Server{}
+	srv.HandleFunc("Test.EchoObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		if r, ok := RequestFromContext(ctx); !ok || r != req {
+			t.Error("request not in context")
+		}
+		switch req.SFuncName {
+		case "echo":
+			return req.SBuffer, nil
+		case "fail":
+			return nil, &gojce.RPCError{Code: -100, Desc: "failed"}
+		case "panic":
+			panic("boom")
+		}
+		return nil, gojce.ErrServantFuncNotFound
+	})
+	addr := startServer(t, srv)
+	defer srv.Close()
+	c := dial(t, addr)
+
+	cases := []struct {
+		servant, fn string
+		ret         int32
+	}{
+		{"Test.EchoObj", "echo", gojce.ServerSuccess},
+		{"Test.EchoObj", "fail", -100},
+		{"Test.EchoObj", "panic", gojce.ServerUnknownErr},
+		{"Test.EchoObj", "missing", gojce.ServerNoFuncErr},
+		{"Test.NoObj", "echo", gojce.ServerNoServantErr},
+	}
+	for i, tc := range cases {
+		rsp := c.call(t, newRequest(int32(i+1), tc.servant, tc.fn))
+		if rsp.IRequestId != int32(i+1) || rsp.IRet != tc.ret {
+			t.Errorf("%s.%s: id %d ret %d, want ret %d", tc.servant, tc.fn, rsp.IRequestId, rsp.IRet, tc.ret)
+		}
+		if tc.ret == gojce.ServerSuccess && string(rsp.SBuffer) != "ping" {
+			t.Errorf("echo: %q", rsp.SBuffer)
+		}
+	}
+
+	// 单向请求没有响应，下一个请求的响应应紧随其后
+	oneway := newRequest(100, "Test.EchoObj", "echo")
+	oneway.CPacketType = gojce.OnewayPacket
+	if err := c.fw.WriteMessage(oneway); err != nil {
+		t.Fatal(err)
+	}
+	if rsp := c.call(t, newRequest(101, "Test.EchoObj", "echo")); rsp.IRequestId != 101 {
+		t.Fatalf("unexpected response %d", rsp.IRequestId)
+	}
+}
+
+func TestServerConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	running := make(chan struct{}, 4)
+	srv := &Server{MaxConcurrentRequests: 1}
+	srv.HandleFunc("Test.SlowObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		running <- struct{}{}
+		<-release
+		return nil, nil
+	})
+	addr := startServer(t, srv)
+	defer srv.Close()
+
+	c1, c2 := dial(t, addr), dial(t, addr)
+	c1.fw.WriteMessage(newRequest(1, "Test.SlowObj", "wait"))
+	c2.fw.WriteMessage(newRequest(2, "Test.SlowObj", "wait"))
+	<-running
+	select {
+	case <-running:
+		t.Fatal("limit exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-running
+}
+
+func TestServerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &Server{}
+	srv.HandleFunc("Test.SlowObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("done"), nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	c := dial(t, l.Addr().String())
+	if err := c.fw.WriteMessage(newRequest(1, "Test.SlowObj", "wait")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned before in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	rsp := new(gojce.ResponsePacket)
+	if err := c.fr.ReadResponse(rsp); err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp.SBuffer) != "done" {
+		t.Fatalf("unexpected response %q", rsp.SBuffer)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("serve returned %v", err)
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatal("listener still open")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv := &Server{}
+	srv.HandleFunc("Test.SlowObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	addr := startServer(t, srv)
+	c := dial(t, addr)
+	c.fw.WriteMessage(newRequest(1, "Test.SlowObj", "wait"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown returned %v", err)
+	}
+}
+
+func TestServerDecodeError(t *testing.T) {
+	srv := &Server{DecoderOptions: gojce.DecoderOptions{MaxStringLength: 16}}
+	srv.HandleFunc("Test.EchoObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		return req.SBuffer, nil
+	})
+	addr := startServer(t, srv)
+	defer srv.Close()
+	c := dial(t, addr)
+
+	// 类型不匹配和超出限制时回复 ServerDecodeErr，连接继续可用
+	var bad []byte
+	bad = gojce.AppendInt16(bad, gojce.JceVersion, 1)
+	bad = gojce.AppendInt32(bad, 1, 4)
+	bad = gojce.AppendInt32(bad, 5, 5)
+	long := newRequest(2, "Test.EchoObj.WithAVeryLongName", "echo")
+	for id, frame := range map[int32][]byte{1: bad, 2: mustMarshal(t, long)} {
+		if err := c.fw.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		rsp := new(gojce.ResponsePacket)
+		if err := c.fr.ReadResponse(rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.IRequestId != id || rsp.IRet != gojce.ServerDecodeErr || rsp.SResultDesc == "" {
+			t.Fatalf("%+v", rsp)
+		}
+	}
+	if rsp := c.call(t, newRequest(3, "Test.EchoObj", "echo")); rsp.IRequestId != 3 || rsp.IRet != gojce.ServerSuccess {
+		t.Fatalf("%+v", rsp)
+	}
+
+	// 取不到 IRequestId 时关闭连接
+	if err := c.fw.WriteFrame([]byte{0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.fr.ReadFrame(); err != io.EOF {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func mustMarshal(t *testing.T, m gojce.Message) []byte {
+	data, err := gojce.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestServerConcurrentShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &Server{}
+	srv.HandleFunc("Test.SlowObj", func(ctx context.Context, req *gojce.RequestPacket) ([]byte, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	addr := startServer(t, srv)
+	c := dial(t, addr)
+	if err := c.fw.WriteMessage(newRequest(1, "Test.SlowObj", "wait")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// 同时调用的 Shutdown 都在请求完成后返回，而不是等到 ctx 超时
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- srv.Shutdown(ctx) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("shutdown not returned after requests finished")
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var ErrUniAttributeNotFound = errors.New("uni attribute not found")
+
+// UniAttribute TUP(WUP) 协议中按名字存取的参数集合
+//
+// TupVersion 时 SBuffer 为 map<string, map<string, vector<byte>>>，内层 key 为类型名；
+// TupVersionV3 时为 map<string, vector<byte>>，不带类型信息
+type UniAttribute struct {
+	version int16
+	data    map[string]map[string][]byte
+	simple  map[string][]byte
+}
+
+func NewUniAttribute(version int16) *UniAttribute {
+	u := &UniAttribute{}
+	u.Reset(version)
+	return u
+}
+
+// Reset 清空所有参数并切换版本
+func (u *UniAttribute) Reset(version int16) {
+	u.version = version
+	u.data = make(map[string]map[string][]byte)
+	u.simple = make(map[string][]byte)
+}
+
+func (u *UniAttribute) Version() int16 {
+	return u.version
+}
+
+// Put 以 tag 0 编码 v 并保存为 name
+func (u *UniAttribute) Put(name string, v any) error {
+	buf := new(bytes.Buffer)
+	e := NewEncoder(buf)
+	if err := e.Encode(v, 0); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if u.version == TupVersionV3 {
+		u.simple[name] = buf.Bytes()
+		return nil
+	}
+	u.data[name] = map[string][]byte{
+		JceTypeName(reflect.TypeOf(v)): buf.Bytes(),
+	}
+	return nil
+}
+
+// Get 解码 name 对应的参数到 v，v 必须是非空指针
+func (u *UniAttribute) Get(name string, v any) error {
+	var data []byte
+	if u.version == TupVersionV3 {
+		b, ok := u.simple[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUniAttributeNotFound, name)
+		}
+		data = b
+	} else {
+		typed, ok := u.data[name]
+		if !ok || len(typed) == 0 {
+			return fmt.Errorf("%w: %s", ErrUniAttributeNotFound, name)
+		}
+		want := JceTypeName(reflect.TypeOf(v))
+		for typeName, b := range typed {
+			if typeName != want {
+				return fmt.Errorf("uni attribute %s type mismatch, want: %s, get: %s", name, want, typeName)
+			}
+			data = b
+		}
+	}
+	return NewDecoder(bytes.NewReader(data)).Decode(v, 0, true)
+}
+
+// Has 是否存在名为 name 的参数
+func (u *UniAttribute) Has(name string) bool {
+	if u.version == TupVersionV3 {
+		_, ok := u.simple[name]
+		return ok
+	}
+	_, ok := u.data[name]
+	return ok
+}
+
+// Remove 删除名为 name 的参数
+func (u *UniAttribute) Remove(name string) {
+	delete(u.data, name)
+	delete(u.simple, name)
+}
+
+func (u *UniAttribute) Encode(w io.Writer) error {
+	encoder := NewEncoder(w)
+	var err error
+	if u.version == TupVersionV3 {
+		err = encoder.WriteMap(u.simple, 0)
+	} else {
+		err = encoder.WriteMap(u.data, 0)
+	}
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (u *UniAttribute) Decode(r io.Reader) error {
+	decoder := NewDecoder(r)
+	if u.version == TupVersionV3 {
+		u.simple = make(map[string][]byte)
+		return decoder.ReadMap(&u.simple, 0, true)
+	}
+	u.data = make(map[string]map[string][]byte)
+	return decoder.ReadMap(&u.data, 0, true)
+}
+
+// UniPacket 使用 TUP 协议的请求包，参数保存在 RequestPacket.SBuffer 中
+type UniPacket struct {
+	UniAttribute
+	RequestId   int32
+	ServantName string
+	FuncName    string
+	Timeout     int32
+	Context     map[string]string
+	Status      map[string]string
+}
+
+func NewUniPacket(version int16) *UniPacket {
+	p := &UniPacket{}
+	p.UniAttribute.Reset(version)
+	return p
+}
+
+// RequestPacket 转换为 RequestPacket
+func (p *UniPacket) RequestPacket() (*RequestPacket, error) {
+	buf := new(bytes.Buffer)
+	if err := p.UniAttribute.Encode(buf); err != nil {
+		return nil, err
+	}
+	return &RequestPacket{
+		IVersion:     p.version,
+		CPacketType:  NormalPacket,
+		IRequestId:   p.RequestId,
+		SServantName: p.ServantName,
+		SFuncName:    p.FuncName,
+		SBuffer:      buf.Bytes(),
+		ITimeout:     p.Timeout,
+		Context:      p.Context,
+		Status:       p.Status,
+	}, nil
+}
+
+// SetRequestPacket 从 RequestPacket 中读取 TUP 参数
+func (p *UniPacket) SetRequestPacket(req *RequestPacket) error {
+	if req.IVersion != TupVersion && req.IVersion != TupVersionV3 {
+		return fmt.Errorf("invalid tup version: %d", req.IVersion)
+	}
+	p.UniAttribute.Reset(req.IVersion)
+	if err := p.UniAttribute.Decode(bytes.NewReader(req.SBuffer)); err != nil {
+		return err
+	}
+	p.RequestId = req.IRequestId
+	p.ServantName = req.SServantName
+	p.FuncName = req.SFuncName
+	p.Timeout = req.ITimeout
+	p.Context = req.Context
+	p.Status = req.Status
+	return nil
+}
+
+func (p *UniPacket) Encode(w io.Writer) error {
+	req, err := p.RequestPacket()
+	if err != nil {
+		return err
+	}
+	return req.Encode(w)
+}
+
+func (p *UniPacket) Decode(r io.Reader) error {
+	var req RequestPacket
+	if err := req.Decode(r); err != nil {
+		return err
+	}
+	return p.SetRequestPacket(&req)
+}
+
+var messageType = reflect.TypeOf(new(Message)).Elem()
+
+// JceTypeName 返回 TUP 协议中使用的类型名，如 int32、list<string>、map<string,int64>
+func JceTypeName(t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Uint8:
+		return "char"
+	case reflect.Int16:
+		return "short"
+	case reflect.Uint16, reflect.Int32:
+		return "int32"
+	case reflect.Uint32, reflect.Int64, reflect.Int, reflect.Uint, reflect.Uint64:
+		return "int64"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "list<char>"
+		}
+		return "list<" + JceTypeName(t.Elem()) + ">"
+	case reflect.Map:
+		return "map<" + JceTypeName(t.Key()) + "," + JceTypeName(t.Elem()) + ">"
+	case reflect.Ptr:
+		if t.Implements(messageType) {
+			return reflect.New(t.Elem()).Interface().(Message).ClassName()
+		}
+		return JceTypeName(t.Elem())
+	case reflect.Struct:
+		if reflect.PtrTo(t).Implements(messageType) {
+			return reflect.New(t).Interface().(Message).ClassName()
+		}
+		return t.Name()
+	}
+	return t.String()
+}
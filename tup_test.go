@@ -0,0 +1,86 @@
+package gojce
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJceTypeName(t *testing.T) {
+	cases := []struct {
+		v    any
+		name string
+	}{
+		{int32(1), "int32"},
+		{int64(1), "int64"},
+		{int16(1), "short"},
+		{byte(1), "char"},
+		{true, "bool"},
+		{float32(1), "float"},
+		{1.0, "double"},
+		{"s", "string"},
+		{[]byte("s"), "list<char>"},
+		{[]string{}, "list<string>"},
+		{map[string][]int32{}, "map<string,list<int32>>"},
+		{&RequestPacket{}, "tars.RequestPacket"},
+		{new(int32), "int32"},
+		{taggedInner{}, "taggedInner"},
+	}
+	for _, c := range cases {
+		if name := JceTypeName(reflect.TypeOf(c.v)); name != c.name {
+			t.Fatalf("%T: %s != %s", c.v, name, c.name)
+		}
+	}
+}
+
+func TestUniPacket(t *testing.T) {
+	for _, version := range []int16{TupVersion, TupVersionV3} {
+		p1 := NewUniPacket(version)
+		p1.RequestId = 3
+		p1.ServantName = "Test.HelloServer.HelloObj"
+		p1.FuncName = "sayHello"
+		if err := p1.Put("id", int32(10086)); err != nil {
+			t.Fatal(err)
+		}
+		if err := p1.Put("names", []string{"a", "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := p1.Put("inner", &taggedInner{Name: "inner", Score: 2}); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := p1.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := &UniPacket{}
+		if err := p2.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if p2.Version() != version || p2.FuncName != "sayHello" || p2.RequestId != 3 {
+			t.Fatalf("%+v", p2)
+		}
+		var id int32
+		if err := p2.Get("id", &id); err != nil || id != 10086 {
+			t.Fatal(id, err)
+		}
+		var names []string
+		if err := p2.Get("names", &names); err != nil || !reflect.DeepEqual(names, []string{"a", "b"}) {
+			t.Fatal(names, err)
+		}
+		var inner taggedInner
+		if err := p2.Get("inner", &inner); err != nil || inner.Name != "inner" || inner.Score != 2 {
+			t.Fatal(inner, err)
+		}
+		if err := p2.Get("missing", &id); !errors.Is(err, ErrUniAttributeNotFound) {
+			t.Fatal(err)
+		}
+		if version == TupVersion {
+			var s string
+			if err := p2.Get("id", &s); err == nil {
+				t.Fatal("expect type mismatch")
+			}
+		}
+	}
+}
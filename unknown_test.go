@@ -0,0 +1,101 @@
+package gojce
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type unknownNew struct {
+	ID    int32             `tag:"1"`
+	Note  string            `tag:"3"`
+	Name  string            `tag:"5"`
+	Items []taggedInner     `tag:"7"`
+	Attrs map[string]string `tag:"20"`
+}
+
+type unknownOld struct {
+	ID      int32  `tag:"1"`
+	Name    string `tag:"5"`
+	Unknown UnknownFields
+}
+
+type unknownOuter struct {
+	List []unknownOld         `tag:"0"`
+	Map  map[int32]unknownOld `tag:"1"`
+}
+
+func TestUnknownFields(t *testing.T) {
+	val := unknownNew{
+		ID:    1,
+		Note:  "note",
+		Name:  "name",
+		Items: []taggedInner{{Name: "a", Score: 1.5}},
+		Attrs: map[string]string{"k": "v"},
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var old unknownOld
+	if err := UnmarshalStruct(data, &old); err != nil {
+		t.Fatal(err)
+	}
+	if old.ID != 1 || old.Name != "name" || len(old.Unknown) == 0 {
+		t.Fatalf("%+v", old)
+	}
+	got, err := MarshalStruct(&old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%x != %x", got, data)
+	}
+
+	// 作为结构体字段时从内存解码
+	e := NewBufferEncoder(nil)
+	if err := e.Encode(&val, 0); err != nil {
+		t.Fatal(err)
+	}
+	var field unknownOld
+	if err := NewBytesDecoder(e.Bytes()).Decode(&field, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(field, old) {
+		t.Fatalf("%+v != %+v", field, old)
+	}
+
+	// 嵌套在 vector 和 map 中的结构体
+	var outerNew struct {
+		List []unknownNew         `tag:"0"`
+		Map  map[int32]unknownNew `tag:"1"`
+	}
+	outerNew.List = []unknownNew{val, {ID: 2, Note: "x"}}
+	outerNew.Map = map[int32]unknownNew{3: val}
+	data, err = MarshalStruct(&outerNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outer unknownOuter
+	if err := UnmarshalStruct(data, &outer); err != nil {
+		t.Fatal(err)
+	}
+	if len(outer.List) != 2 || len(outer.List[1].Unknown) == 0 || len(outer.Map[3].Unknown) == 0 {
+		t.Fatalf("%+v", outer)
+	}
+	if got, err = MarshalStruct(&outer); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%x != %x", got, data)
+	}
+
+	// 解码时覆盖原有的未知字段
+	if err := UnmarshalStruct(data[:0], &old); err != nil {
+		t.Fatal(err)
+	}
+	if len(old.Unknown) != 0 {
+		t.Fatalf("%x", old.Unknown)
+	}
+}
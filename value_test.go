@@ -0,0 +1,121 @@
+package gojce
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeAny(t *testing.T) {
+	val := taggedPacket{
+		ID:      1000,
+		Title:   "any",
+		Payload: []byte{1, 2, 3},
+		Items:   []taggedInner{{Name: "a", Score: 0.5}},
+		Attrs:   map[string]taggedInner{"k": {Name: "v"}},
+		Inner:   taggedInner{Name: "inner"},
+		Comment: "tag 20",
+	}
+	data, err := MarshalStruct(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := DecodeAny(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []JceTag{1, 2, 3, 4, 5, 6, 20}
+	if len(fields) != len(tags) {
+		t.Fatalf("got %d fields", len(fields))
+	}
+	for i, f := range fields {
+		if f.Tag != tags[i] {
+			t.Fatalf("field %d tag %d, want %d", i, f.Tag, tags[i])
+		}
+	}
+	if v := fields[0].Value; v.Type != Int16 || v.Int != 1000 {
+		t.Fatalf("ID: %+v", v)
+	}
+	if v := fields[2].Value; v.Type != SimpleList || !bytes.Equal(v.Bytes, val.Payload) {
+		t.Fatalf("Payload: %+v", v)
+	}
+	items := fields[3].Value
+	if items.Type != List || len(items.List) != 1 || items.List[0].Type != StructBegin {
+		t.Fatalf("Items: %+v", items)
+	}
+	if f := items.List[0].Fields; len(f) != 2 || string(f[0].Value.Bytes) != "a" || f[1].Value.Float != 0.5 {
+		t.Fatalf("Items[0]: %+v", f)
+	}
+	if m := fields[4].Value.Map; len(m) != 1 || string(m[0].Key.Bytes) != "k" {
+		t.Fatalf("Attrs: %+v", m)
+	}
+
+	out, err := AppendAny(nil, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatalf("%x != %x", out, data)
+	}
+
+	// 修改后的树仍能被有类型的解码器读取
+	fields[0].Value = Value{Type: Int32, Int: 1 << 20}
+	fields[3].Value.List = append(fields[3].Value.List, Value{Type: StructBegin, Fields: []Field{
+		{Tag: 0, Value: Value{Type: String1, Bytes: []byte("b")}},
+	}})
+	var buf bytes.Buffer
+	if err := EncodeAny(&buf, fields); err != nil {
+		t.Fatal(err)
+	}
+	var val2 taggedPacket
+	if err := UnmarshalStruct(buf.Bytes(), &val2); err != nil {
+		t.Fatal(err)
+	}
+	val.ID = 1 << 20
+	val.Items = append(val.Items, taggedInner{Name: "b"})
+	if !reflect.DeepEqual(val, val2) {
+		t.Fatalf("%+v != %+v", val, val2)
+	}
+}
+
+func TestDecodeAnyNonCanonical(t *testing.T) {
+	cases := []string{
+		// tag 0 用 Int32 编码的 1
+		"0200000001",
+		// tag 1 使用两字节字段头
+		"f00105",
+		// String4 编码的短字符串
+		"17000000026869",
+		// List 长度用 Int16 编码，元素 tag 为 1
+		"2901000210011002",
+		// SimpleList，长度为 Zero
+		"3d000c",
+		// 结构体，StructEnd 的 tag 为 1
+		"4a0c0c1b",
+	}
+	for _, c := range cases {
+		data, _ := hex.DecodeString(c)
+		fields, err := NewBytesDecoder(data).DecodeAny()
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		out, err := AppendAny(nil, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: got %x", c, out)
+		}
+	}
+
+	for _, c := range []string{"02000000", "0b", "0a0c", "09000a"} {
+		data, _ := hex.DecodeString(c)
+		if _, err := DecodeAny(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expect error", c)
+		}
+	}
+	if _, err := AppendAny(nil, []Field{{Tag: 0, Value: Value{Type: Int8, Int: 300}}}); err == nil {
+		t.Error("expect out of range error")
+	}
+}